@@ -1,35 +1,20 @@
 package main
 
 import (
-	"bufio"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"os"
-	"regexp"
 	"sort"
-	"strings"
 
-	"github.com/samber/lo"
 	"github.com/tgoodwin/sleeve/pkg/event"
 	"github.com/tgoodwin/sleeve/pkg/snapshot"
-	"github.com/tgoodwin/sleeve/pkg/tag"
+	"github.com/tgoodwin/sleeve/pkg/trace"
 )
 
 var inFile = flag.String("logfile", "default.log", "path to the log file")
 
-var logTypes = []string{tag.ControllerOperationKey, tag.ObjectVersionKey}
-var pattern = regexp.MustCompile(`{"LogType": "(?:` + strings.Join(logTypes, "|") + `)"}`)
-
-func stripLogtype(line string) string {
-	return pattern.ReplaceAllString(line, "")
-}
-
-func stripLogtypeFromLines(lines []string) []string {
-	return lo.Map(lines, func(line string, _ int) string {
-		return stripLogtype(line)
-	})
-}
-
 func main() {
 	flag.Parse()
 	f, err := os.Open(*inFile)
@@ -38,61 +23,34 @@ func main() {
 	}
 	defer f.Close()
 
-	scanner := bufio.NewScanner(f)
-	var lines []string
-	for scanner.Scan() {
-		lines = append(lines, scanner.Text())
-	}
-	if err := scanner.Err(); err != nil {
-		panic(err.Error())
-	}
-
-	lines = lo.FilterMap(lines, func(l string, _ int) (string, bool) {
-		isSleeveLine := strings.Contains(l, tag.LoggerName)
-		parts := strings.SplitN(l, tag.LoggerName, 2)
-		if len(parts) > 1 {
-			l = strings.TrimSpace(parts[1])
+	// the trace is streamed, so only the version keys are held in memory
+	events, malformed := 0, 0
+	comp := make(map[snapshot.VersionKey]event.CausalKey)
+	reader := trace.NewReader(f)
+	for {
+		rec, err := reader.Next()
+		if err == io.EOF {
+			break
 		}
-		return l, isSleeveLine
-	})
-	// fmt.Println("Sleeveless lines:", lines[0])
-
-	// filter controller ops
-	controllerOps := lo.FilterMap(lines, func(l string, _ int) (string, bool) {
-		return l, strings.Contains(l, tag.ControllerOperationKey)
-	})
-	controllerOps = stripLogtypeFromLines(controllerOps)
-	fmt.Println("Controller Operations:", len(controllerOps))
-
-	events := lo.Map(controllerOps, func(l string, _ int) event.Event {
-		var e event.Event
-		if err := e.UnmarshalJSON([]byte(l)); err != nil {
-			panic(err.Error())
+		var decodeErr *trace.DecodeError
+		if errors.As(err, &decodeErr) {
+			fmt.Printf("Skipping malformed record: %s\n", err.Error())
+			malformed++
+			continue
 		}
-		return e
-	})
-	fmt.Println("events:", len(events))
-
-	versions := lo.FilterMap(lines, func(l string, _ int) (string, bool) {
-		return l, strings.Contains(l, tag.ObjectVersionKey)
-	})
-	versions = stripLogtypeFromLines(versions)
-	records := lo.Map(versions, func(v string, i int) snapshot.Record {
-		r, err := snapshot.LoadFromString(v)
 		if err != nil {
 			panic(err.Error())
 		}
-		return r
-	})
-
-	comp := make(map[snapshot.VersionKey]event.CausalKey)
-	for _, r := range records {
-		obj := r.ToUnstructured()
-		vkey := snapshot.VersionKey{
-			Kind:     r.Kind,
-			ObjectID: r.ObjectID,
-			Version:  r.Version,
+		if rec.Event != nil {
+			events++
+			continue
 		}
+		if rec.Snapshot == nil {
+			continue
+		}
+		r := *rec.Snapshot
+		obj := r.ToUnstructured()
+		vkey := r.VersionKey()
 		ckey, err := event.GetCausalKey(obj)
 		if err != nil {
 			fmt.Printf("Error getting causal key: %s\n", err.Error())
@@ -105,6 +63,8 @@ func main() {
 		}
 		comp[vkey] = ckey
 	}
+	fmt.Println("events:", events)
+	fmt.Println("malformed records:", malformed)
 
 	var sortedKeys []snapshot.VersionKey
 	for k := range comp {
@@ -119,6 +79,8 @@ func main() {
 		fmt.Printf("Kind: %s, VersionKey: %s\nCausalKey: %v\n", k.Kind, k.Version, v.Version)
 	}
 
+	fmt.Print("\ntrace integrity:\n", reader.Integrity())
+
 	// recordsByVersion := lo.GroupBy(records, func(r snapshot.Record) string {
 	// 	return r.GetID()
 	// })
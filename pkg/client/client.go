@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"reflect"
+	"strconv"
 	"strings"
 
 	"crypto/sha256"
@@ -18,11 +19,11 @@ import (
 	"github.com/tgoodwin/sleeve/pkg/tag"
 	"github.com/tgoodwin/sleeve/pkg/util"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
-
-	ctrl "sigs.k8s.io/controller-runtime/pkg/controller"
 )
 
 var log = logf.Log.WithName(tag.LoggerName)
@@ -75,21 +76,25 @@ type Client struct {
 	// identifier for the reconciler (controller name)
 	id string
 
-	reconcileContext *ReconcileContext
+	// per-reconcile trace state, keyed by reconcile ID
+	reconcileContexts *reconcileContexts
 
 	logger logr.Logger
 
 	config *Config
+
+	clock *logicalClock
 }
 
 var _ client.Client = (*Client)(nil)
 
 func newClient(wrapped client.Client) *Client {
 	return &Client{
-		Client:           wrapped,
-		logger:           log,
-		reconcileContext: &ReconcileContext{},
-		config:           NewConfig(),
+		Client:            wrapped,
+		logger:            log,
+		reconcileContexts: newReconcileContexts(defaultMaxReconcileContexts),
+		config:            NewConfig(),
+		clock:             &logicalClock{},
 	}
 }
 
@@ -116,6 +121,9 @@ func (c *Client) WithEnvConfig() *Client {
 	if logSnapshots, ok := envVars["SLEEVE_LOG_SNAPSHOTS"]; ok {
 		c.config.LogObjectSnapshots = logSnapshots == "1"
 	}
+	if recordDeltas, ok := envVars["SLEEVE_RECORD_DELTAS"]; ok {
+		c.config.RecordWriteDeltas = recordDeltas == "1"
+	}
 
 	// Log the environment variables
 	for key, value := range envVars {
@@ -125,22 +133,27 @@ func (c *Client) WithEnvConfig() *Client {
 	return c
 }
 
-func (c *Client) setReconcileID(ctx context.Context) {
-	rid := string(ctrl.ReconcileIDFromContext(ctx))
+// reconcileContext returns the trace state for the reconcile invocation that ctx belongs to.
+// Reconcilers wrapped with WrapReconciler carry their state in the context;
+// otherwise it is looked up by reconcile ID.
+func (c *Client) reconcileContext(ctx context.Context) *ReconcileContext {
+	if rc, ok := ctx.Value(reconcileContextKey{}).(*ReconcileContext); ok {
+		return rc
+	}
+	rid := ReconcileIDFromContext(ctx)
 	if rid == "" {
 		// this should never happen given our assumptions
 		panic("reconcileID not set in context")
 	}
+	return c.reconcileContexts.get(rid)
+}
 
-	currReconcileID := c.reconcileContext.GetReconcileID()
-	if currReconcileID == "" {
-		// first time setting reconcileID
-		c.reconcileContext.SetReconcileID(string(rid))
-	} else if rid != currReconcileID {
-		c.logger.V(2).Info("reconcileID changed", "old", currReconcileID, "new", rid)
-		// unset rootID if reconcileID changes
-		c.reconcileContext.SetRootID("")
-		c.reconcileContext.SetReconcileID(string(rid))
+// EndReconcile releases the trace state held for the reconcile invocation that ctx belongs to.
+// Calling it once the reconcile has returned is optional: the Client only keeps the state of
+// a bounded number of reconciles (see MaxTrackedReconciles) and releases the least recently used first.
+func (c *Client) EndReconcile(ctx context.Context) {
+	if rid := ReconcileIDFromContext(ctx); rid != "" {
+		c.reconcileContexts.remove(rid)
 	}
 }
 
@@ -152,33 +165,147 @@ func Operation(obj client.Object, reconcileID, controllerID, rootEventID string,
 		RootEventID:  rootEventID,
 		OpType:       string(op),
 		Kind:         util.GetKind(obj),
+		APIVersion:   obj.GetObjectKind().GroupVersionKind().GroupVersion().String(),
 		ObjectID:     string(obj.GetUID()),
 		Version:      obj.GetResourceVersion(),
+		Namespace:    obj.GetNamespace(),
+		Name:         obj.GetName(),
 		Labels:       obj.GetLabels(),
 	}
 }
 
-func (c *Client) logOperation(obj client.Object, op OperationType) {
-	event := Operation(
+// Absence returns an event for a read that observed that no object exists: a Get of the given key
+// that returned NotFound, or a List (with the given options) that returned no items.
+func Absence(gvk schema.GroupVersionKind, key client.ObjectKey, listOpts *client.ListOptions, reconcileID, controllerID, rootEventID string, op OperationType) *event.Event {
+	e := &event.Event{
+		Timestamp:    event.FormatTimeStr(time.Now()),
+		ReconcileID:  reconcileID,
+		ControllerID: controllerID,
+		RootEventID:  rootEventID,
+		OpType:       string(op),
+		Kind:         gvk.Kind,
+		APIVersion:   gvk.GroupVersion().String(),
+		Namespace:    key.Namespace,
+		Name:         key.Name,
+		Absent:       true,
+	}
+	if listOpts != nil {
+		if listOpts.LabelSelector != nil {
+			e.LabelSelector = listOpts.LabelSelector.String()
+		}
+		if listOpts.FieldSelector != nil {
+			e.FieldSelector = listOpts.FieldSelector.String()
+		}
+	}
+	return e
+}
+
+func (c *Client) operation(rc *ReconcileContext, obj client.Object, op OperationType) *event.Event {
+	e := Operation(
 		obj,
-		c.reconcileContext.GetReconcileID(),
+		rc.GetReconcileID(),
 		c.id,
-		c.reconcileContext.GetRootID(),
+		rc.GetRootID(),
 		op,
 	)
+	gvk := c.groupVersionKindFor(obj)
+	e.APIVersion = gvk.GroupVersion().String()
+	e.Kind = gvk.Kind
+	// the object carries the clock of the write that produced it, which the operation follows
+	e.Clock = c.clock.merge(tag.GetLogicalClock(obj))
+	return e
+}
+
+// groupVersionKindFor returns the GVK of the object. Typed objects often have an empty TypeMeta,
+// so their GVK is resolved through the scheme of the wrapped client.
+func (c *Client) groupVersionKindFor(obj runtime.Object) schema.GroupVersionKind {
+	gvk := util.GetGroupVersionKind(obj)
+	if gvk.Version == "" {
+		if resolved, err := c.GroupVersionKindFor(obj); err == nil {
+			return resolved
+		}
+	}
+	return gvk
+}
+
+func (c *Client) logEvent(event *event.Event) {
 	eventJSON, err := json.Marshal(event)
 	if err != nil {
 		panic("failed to marshal event")
 	}
-	c.logger.WithValues("LogType", tag.ControllerOperationKey).Info(string(eventJSON))
+	c.emit(tag.ControllerOperationKey, string(eventJSON))
+}
+
+// recordPatch records the patch that a PATCH event sends. It must be called before the patch is issued,
+// since patches computed from a base object (e.g. client.MergeFrom) diff against the object's current state.
+func (c *Client) recordPatch(e *event.Event, obj client.Object, patch client.Patch, opts *client.PatchOptions) {
+	e.PatchType = string(patch.Type())
+	if patch.Type() == types.ApplyPatchType {
+		e.FieldManager = opts.FieldManager
+		e.Force = opts.Force != nil && *opts.Force
+	}
+	data, err := patch.Data(obj)
+	if err != nil {
+		c.logger.Error(err, "failed to serialize patch", "kind", e.Kind, "name", e.Name)
+		return
+	}
+	e.PatchSize = len(data)
+	if c.config.MaxPatchSize == 0 || len(data) <= c.config.MaxPatchSize {
+		e.PatchData = string(data)
+	}
+}
+
+// logResult records the outcome of a write on its event and logs it.
+func (c *Client) logResult(e *event.Event, obj client.Object, err error) {
+	setResult(e, obj, err)
+	c.logEvent(e)
+}
+
+// setResult records the outcome of a write on its event.
+// On success, the event carries the identity and resourceVersion of obj as the write left it.
+func setResult(e *event.Event, obj client.Object, err error) {
+	if err != nil {
+		e.Outcome = event.OutcomeFailure
+		e.ErrorReason = string(apierrors.ReasonForError(err))
+		e.Error = err.Error()
+	} else {
+		e.Outcome = event.OutcomeSuccess
+		e.ResultObjectID = string(obj.GetUID())
+		e.ResultVersion = obj.GetResourceVersion()
+		// names generated by the apiserver are only known once the create returns
+		if e.Name == "" {
+			e.Name = obj.GetName()
+		}
+	}
+}
+
+// logFault records a perturbation that the client injected into an operation.
+func (c *Client) logFault(rc *ReconcileContext, f *event.Fault) {
+	f.Timestamp = event.FormatTimeStr(time.Now())
+	f.ReconcileID = rc.GetReconcileID()
+	f.ControllerID = c.id
+	faultJSON, err := json.Marshal(f)
+	if err != nil {
+		panic("failed to marshal fault")
+	}
+	c.emit(tag.FaultKey, string(faultJSON))
 }
 
 func (c *Client) logObjectVersion(obj client.Object) {
+	// the snapshot must carry its apiVersion and kind to be decoded later
+	if obj.GetObjectKind().GroupVersionKind().Empty() {
+		obj = obj.DeepCopyObject().(client.Object)
+		obj.GetObjectKind().SetGroupVersionKind(c.groupVersionKindFor(obj))
+	}
 	r := snapshot.RecordValue(obj)
-	c.logger.WithValues("LogType", tag.ObjectVersionKey).Info(r)
+	c.emit(tag.ObjectVersionKey, r)
+}
+
+func (c *Client) emit(logType, payload string) {
+	Emit(c.config.Sink, c.logger, logType, payload)
 }
 
-func (c *Client) setRootContext(obj client.Object) {
+func (c *Client) setRootContext(rc *ReconcileContext, obj client.Object) {
 	labels := obj.GetLabels()
 	// set by the webhook
 	rootID, ok := labels[tag.TraceyWebhookLabel]
@@ -190,42 +317,45 @@ func (c *Client) setRootContext(obj client.Object) {
 			return
 		}
 	}
-	currRootID := c.reconcileContext.GetRootID()
+	currRootID := rc.GetRootID()
 	if currRootID != "" && currRootID != rootID {
 		c.logger.WithValues(
 			"ControllerID", c.id,
-			"ReconcileID", c.reconcileContext.GetReconcileID(),
-			"RootID", c.reconcileContext.GetRootID(),
+			"ReconcileID", rc.GetReconcileID(),
+			"RootID", currRootID,
 			"NewRootID", rootID,
 		).V(2).Error(nil, "Root context changed during reconcile")
 	}
-	c.reconcileContext.SetRootID(rootID)
+	rc.SetRootID(rootID)
 }
 
-func (c *Client) propagateLabels(obj client.Object) {
+func (c *Client) propagateLabels(rc *ReconcileContext, obj client.Object, clock uint64) {
 	currLabels := obj.GetLabels()
 	out := make(map[string]string)
 	for k, v := range currLabels {
 		out[k] = v
 	}
 	out[tag.TraceyCreatorID] = c.id
-	out[tag.TraceyRootID] = c.reconcileContext.GetRootID()
-	out[tag.TraceyReconcileID] = c.reconcileContext.GetReconcileID()
+	out[tag.TraceyRootID] = rc.GetRootID()
+	out[tag.TraceyReconcileID] = rc.GetReconcileID()
+	out[tag.LogicalClock] = strconv.FormatUint(clock, 10)
 
 	obj.SetLabels(out)
 }
 
-func (c *Client) trackOperation(ctx context.Context, obj client.Object, op OperationType) {
+// prepareOperation labels obj for the given operation and returns the event describing it.
+// The event is not logged, so that write operations can log it along with their outcome.
+func (c *Client) prepareOperation(ctx context.Context, obj client.Object, op OperationType) *event.Event {
 	// crash if any of our labeling assumptions are violated
 	if err := tag.SanityCheckLabels(obj); err != nil {
 		panic(err)
 	}
 
-	c.setReconcileID(ctx)
+	rc := c.reconcileContext(ctx)
 
 	// for read operations, set the root context for this reconcile invocation
 	if op == GET || op == LIST {
-		c.setRootContext(obj)
+		c.setRootContext(rc, obj)
 
 		// only log versions as they are observed during read operations
 		// otherwise the logged version might miss some defaulted values
@@ -233,32 +363,111 @@ func (c *Client) trackOperation(ctx context.Context, obj client.Object, op Opera
 		if c.config.LogObjectSnapshots {
 			c.logObjectVersion(obj)
 		}
+		c.observe(ctx, obj)
 	}
 	if _, ok := mutationTypes[op]; ok {
 		tag.LabelChange(obj)
 	}
-	c.logOperation(obj, op)
-	// propagate labels after logging so we capture the label values prior to the operation
+	e := c.operation(rc, obj, op)
+	// propagate labels after creating the event so we capture the label values prior to the operation
 	// e.g. we want to log out "prev-write-reconcile-id" before it gets overwritten with the current reconcileID
-	c.propagateLabels(obj)
+	c.propagateLabels(rc, obj, e.Clock)
+	return e
+}
+
+// absence returns an event for a read that observed that no object exists.
+// For lists, obj is the list and the event records the kind of its items.
+func (c *Client) absence(ctx context.Context, obj runtime.Object, key client.ObjectKey, listOpts *client.ListOptions, op OperationType) *event.Event {
+	rc := c.reconcileContext(ctx)
+	gvk := c.groupVersionKindFor(obj)
+	if list, ok := obj.(client.ObjectList); ok && op == LIST {
+		gvk = c.listItemGVK(list)
+	}
+	e := Absence(gvk, key, listOpts, rc.GetReconcileID(), c.id, rc.GetRootID(), op)
+	e.Clock = c.clock.tick()
+	return e
+}
+
+// listObservation returns a record of a List call, to which the items are added as they are traced.
+func (c *Client) listObservation(ctx context.Context, list client.ObjectList, listOpts *client.ListOptions) *event.ListObservation {
+	rc := c.reconcileContext(ctx)
+	gvk := c.groupVersionKindFor(list)
+	o := &event.ListObservation{
+		Timestamp:       event.FormatTimeStr(time.Now()),
+		ReconcileID:     rc.GetReconcileID(),
+		ControllerID:    c.id,
+		ListID:          util.UUID(),
+		Kind:            strings.TrimSuffix(gvk.Kind, "List"),
+		APIVersion:      gvk.GroupVersion().String(),
+		Namespace:       listOpts.Namespace,
+		Limit:           listOpts.Limit,
+		Continue:        listOpts.Continue,
+		ResourceVersion: list.GetResourceVersion(),
+		Items:           make([]event.ListItem, 0),
+	}
+	if listOpts.LabelSelector != nil {
+		o.LabelSelector = listOpts.LabelSelector.String()
+	}
+	if listOpts.FieldSelector != nil {
+		o.FieldSelector = listOpts.FieldSelector.String()
+	}
+	return o
+}
+
+func (c *Client) logListObservation(o *event.ListObservation) {
+	payload, err := json.Marshal(o)
+	if err != nil {
+		panic("failed to marshal list observation")
+	}
+	c.emit(tag.ListObservationKey, string(payload))
+}
+
+func (c *Client) trackOperation(ctx context.Context, obj client.Object, op OperationType) {
+	c.logEvent(c.prepareOperation(ctx, obj, op))
+}
+
+// write performs a traced write. The event is prepared before the write is issued
+// and logged along with its outcome once the write returns.
+func (c *Client) write(ctx context.Context, obj client.Object, op OperationType, do func() error) error {
+	return c.issue(ctx, c.prepareOperation(ctx, obj, op), obj, op, do)
+}
+
+// issue performs a write whose event has already been prepared.
+func (c *Client) issue(ctx context.Context, e *event.Event, obj client.Object, op OperationType, do func() error) error {
+	err := c.injectError(ctx, c.groupVersionKindFor(obj), client.ObjectKeyFromObject(obj), op)
+	if err == nil {
+		err = do()
+	}
+	if err == nil {
+		c.observeWrite(ctx, e, obj, op)
+	}
+	c.logResult(e, obj, err)
+	return err
 }
 
 func (c *Client) Create(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
-	c.trackOperation(ctx, obj, CREATE)
-	return c.Client.Create(ctx, obj, opts...)
+	return c.write(ctx, obj, CREATE, func() error {
+		return c.Client.Create(ctx, obj, opts...)
+	})
 }
 
 func (c *Client) Delete(ctx context.Context, obj client.Object, opts ...client.DeleteOption) error {
-	c.trackOperation(ctx, obj, DELETE)
-	return c.Client.Delete(ctx, obj, opts...)
+	return c.write(ctx, obj, DELETE, func() error {
+		return c.Client.Delete(ctx, obj, opts...)
+	})
 }
 
 func (c *Client) DeleteAllOf(ctx context.Context, obj client.Object, opts ...client.DeleteAllOfOption) error {
-	c.trackOperation(ctx, obj, DELETE)
-	return c.Client.DeleteAllOf(ctx, obj, opts...)
+	return c.write(ctx, obj, DELETE, func() error {
+		return c.Client.DeleteAllOf(ctx, obj, opts...)
+	})
 }
 
 func (c *Client) Get(ctx context.Context, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+	if err := c.injectError(ctx, c.groupVersionKindFor(obj), key, GET); err != nil {
+		return err
+	}
+
 	// cast back to a client.Ojbject
 	objCopy, ok := obj.DeepCopyObject().(client.Object)
 	if !ok {
@@ -266,46 +475,29 @@ func (c *Client) Get(ctx context.Context, key client.ObjectKey, obj client.Objec
 	}
 
 	if err := c.Client.Get(ctx, key, objCopy, opts...); err != nil {
+		if apierrors.IsNotFound(err) {
+			c.logEvent(c.absence(ctx, obj, key, nil, GET))
+		}
 		return err
 	}
-	isVisible := c.isVisible(objCopy)
-	if !isVisible {
+	rc := c.reconcileContext(ctx)
+	served, visible := c.applyVisibility(rc, objCopy, GET)
+	if !visible {
+		c.logEvent(c.absence(ctx, obj, key, nil, GET))
 		return apierrors.NewNotFound(schema.GroupResource{}, key.Name)
 	}
-	err := c.Client.Get(ctx, key, obj, opts...)
+	setObject(obj, served)
+	c.maybeServeStale(rc, obj, GET)
 	c.trackOperation(ctx, obj, GET)
-	return err
-}
-
-func (c *Client) isVisible(obj client.Object) bool {
-	kind := obj.GetObjectKind().GroupVersionKind().Kind
-	if visDelay, ok := c.config.visibilityDelayByKind[kind]; ok {
-		now := time.Now()
-		created := obj.GetCreationTimestamp().Time
-		if now.Sub(created) < visDelay {
-			c.logger.WithValues(
-				"ObjectKind", kind,
-				"ObjectUID", obj.GetUID(),
-				"TimeSinceCreated", now.Sub(created),
-			).V(1).Info("Object not visible yet")
-			return false
-		}
-		return true
-	}
-	return true
+	return nil
 }
 
-func (c *Client) filterVisible(objs []client.Object) []client.Object {
-	visible := make([]client.Object, 0)
-	for _, obj := range objs {
-		if c.isVisible(obj) {
-			visible = append(visible, obj)
-		}
+func (c *Client) List(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+	listOpts := (&client.ListOptions{}).ApplyOptions(opts)
+	if err := c.injectError(ctx, c.listItemGVK(list), client.ObjectKey{Namespace: listOpts.Namespace}, LIST); err != nil {
+		return err
 	}
-	return visible
-}
 
-func (c *Client) List(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
 	// Perform the List operation on the wrapped client
 	lc := list.DeepCopyObject().(client.ObjectList)
 	if err := c.Client.List(ctx, lc, opts...); err != nil {
@@ -319,14 +511,38 @@ func (c *Client) List(ctx context.Context, list client.ObjectList, opts ...clien
 	}
 
 	// create a new slice to hold the items
+	rc := c.reconcileContext(ctx)
+	observation := c.listObservation(ctx, lc, listOpts)
 	out := reflect.MakeSlice(itemsValue.Type(), 0, itemsValue.Len())
 	for i := 0; i < itemsValue.Len(); i++ {
 		item := itemsValue.Index(i).Addr().Interface().(client.Object)
-		// instead of treating the LIST operation as a singular observation event,
-		// we treat each item in the list as a separate event
-		c.trackOperation(ctx, item, LIST)
+		served, visible := c.applyVisibility(rc, item, LIST)
+		if !visible {
+			continue
+		}
+		setObject(item, served)
+		c.maybeServeStale(rc, item, LIST)
+		// each item in the list is also traced as a separate event,
+		// which is linked to the observation of the list as a whole
+		e := c.prepareOperation(ctx, item, LIST)
+		e.ListID = observation.ListID
+		c.logEvent(e)
+		observation.Items = append(observation.Items, event.ListItem{
+			ObjectID:    e.ObjectID,
+			Namespace:   e.Namespace,
+			Name:        e.Name,
+			Version:     e.Version,
+			ChangeID:    string(e.ChangeID()),
+			RootEventID: tag.GetRootID(item),
+		})
 		out = reflect.Append(out, itemsValue.Index(i))
 	}
+	if out.Len() == 0 {
+		e := c.absence(ctx, list, client.ObjectKey{Namespace: listOpts.Namespace}, listOpts, LIST)
+		e.ListID = observation.ListID
+		c.logEvent(e)
+	}
+	c.logListObservation(observation)
 
 	// Set the items back to the original list
 	originalItemsValue := reflect.ValueOf(list).Elem().FieldByName("Items")
@@ -339,11 +555,21 @@ func (c *Client) List(ctx context.Context, list client.ObjectList, opts ...clien
 }
 
 func (c *Client) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
-	c.trackOperation(ctx, obj, UPDATE)
-	return c.Client.Update(ctx, obj, opts...)
+	e := c.prepareOperation(ctx, obj, UPDATE)
+	c.recordDelta(ctx, e, obj)
+	return c.issue(ctx, e, obj, UPDATE, func() error {
+		return c.Client.Update(ctx, obj, opts...)
+	})
 }
 
 func (c *Client) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
-	c.trackOperation(ctx, obj, PATCH)
-	return c.Client.Patch(ctx, obj, patch, opts...)
+	e := c.prepareOperation(ctx, obj, PATCH)
+	c.recordPatch(e, obj, patch, (&client.PatchOptions{}).ApplyOptions(opts))
+	// an apply patch only holds the fields the controller manages, so it is its own record of intent
+	if patch.Type() != types.ApplyPatchType {
+		c.recordDelta(ctx, e, obj)
+	}
+	return c.issue(ctx, e, obj, PATCH, func() error {
+		return c.Client.Patch(ctx, obj, patch, opts...)
+	})
 }
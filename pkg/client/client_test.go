@@ -1,6 +1,22 @@
 package client
 
-import "testing"
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/samber/lo"
+	"github.com/tgoodwin/sleeve/pkg/event"
+	"github.com/tgoodwin/sleeve/pkg/tag"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
 
 func Test_createFixedLengthHash(t *testing.T) {
 	type args struct {
@@ -24,3 +40,293 @@ func Test_createFixedLengthHash(t *testing.T) {
 		})
 	}
 }
+
+func TestConcurrentReconcileRootIDs(t *testing.T) {
+	const numReconciles = 50
+
+	objs := make([]client.Object, 0, numReconciles)
+	for i := 0; i < numReconciles; i++ {
+		objs = append(objs, &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      fmt.Sprintf("cm-%d", i),
+				Namespace: "default",
+				Labels:    map[string]string{tag.TraceyWebhookLabel: fmt.Sprintf("root-%d", i)},
+			},
+		})
+	}
+	sink := NewMemorySink()
+	c := Wrap(fake.NewClientBuilder().WithObjects(objs...).Build()).
+		WithName("test-controller").
+		WithOptions(WithSink(sink))
+
+	var wg sync.WaitGroup
+	for i := 0; i < numReconciles; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			ctx := WithReconcileID(context.Background(), fmt.Sprintf("reconcile-%d", i))
+			defer c.EndReconcile(ctx)
+
+			cm := &corev1.ConfigMap{}
+			if err := c.Get(ctx, types.NamespacedName{Namespace: "default", Name: fmt.Sprintf("cm-%d", i)}, cm); err != nil {
+				t.Errorf("get failed: %v", err)
+				return
+			}
+			child := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("child-%d", i), Namespace: "default"}}
+			if err := c.Create(ctx, child); err != nil {
+				t.Errorf("create failed: %v", err)
+			}
+			if child.GetLabels()[tag.TraceyRootID] != fmt.Sprintf("root-%d", i) {
+				t.Errorf("child %d propagated root ID %q", i, child.GetLabels()[tag.TraceyRootID])
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	events, err := sink.Events()
+	if err != nil {
+		t.Fatalf("failed to decode events: %v", err)
+	}
+	if len(events) != 2*numReconciles {
+		t.Fatalf("expected %d events, got %d", 2*numReconciles, len(events))
+	}
+	for _, e := range events {
+		var i int
+		if _, err := fmt.Sscanf(e.ReconcileID, "reconcile-%d", &i); err != nil {
+			t.Fatalf("unexpected reconcile ID %q", e.ReconcileID)
+		}
+		if want := fmt.Sprintf("root-%d", i); e.RootEventID != want {
+			t.Errorf("%s event in %s has root ID %q, want %q", e.OpType, e.ReconcileID, e.RootEventID, want)
+		}
+	}
+	if n := c.reconcileContexts.len(); n != 0 {
+		t.Errorf("expected reconcile state to be released, %d entries remain", n)
+	}
+}
+
+func TestReconcileStateIsBounded(t *testing.T) {
+	cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "cm", Namespace: "default"}}
+	c := Wrap(fake.NewClientBuilder().WithObjects(cm).Build()).
+		WithName("test-controller").
+		WithOptions(WithSink(NewMemorySink()), MaxTrackedReconciles(4))
+
+	for i := 0; i < 10; i++ {
+		// reconciles that are never ended, as with a controller that is not wrapped with WrapReconciler
+		ctx := WithReconcileID(context.Background(), fmt.Sprintf("reconcile-%d", i))
+		if err := c.Get(ctx, client.ObjectKeyFromObject(cm), &corev1.ConfigMap{}); err != nil {
+			t.Fatalf("get failed: %v", err)
+		}
+	}
+	if n := c.reconcileContexts.len(); n != 4 {
+		t.Fatalf("expected state for 4 reconciles to be kept, got %d", n)
+	}
+	for i := 6; i < 10; i++ {
+		if _, ok := c.reconcileContexts.byID[fmt.Sprintf("reconcile-%d", i)]; !ok {
+			t.Errorf("expected the state of recent reconcile-%d to be kept", i)
+		}
+	}
+}
+
+func TestWriteOutcomes(t *testing.T) {
+	sink := NewMemorySink()
+	c := Wrap(fake.NewClientBuilder().Build()).WithName("test-controller").WithOptions(WithSink(sink))
+	ctx := WithReconcileID(context.Background(), "reconcile-1")
+
+	cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "cm", Namespace: "default"}}
+	if err := c.Create(ctx, cm); err != nil {
+		t.Fatalf("create failed: %v", err)
+	}
+	dup := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "cm", Namespace: "default"}}
+	if err := c.Create(ctx, dup); err == nil {
+		t.Fatalf("expected duplicate create to fail")
+	}
+
+	events, err := sink.Events()
+	if err != nil {
+		t.Fatalf("failed to decode events: %v", err)
+	}
+	if len(events) != 2 {
+		t.Fatalf("expected 2 events, got %d", len(events))
+	}
+	if events[0].Failed() || events[0].ResultVersion != cm.GetResourceVersion() || events[0].ResultObjectID != string(cm.GetUID()) {
+		t.Errorf("unexpected outcome for successful create: %+v", events[0])
+	}
+	if !events[1].Failed() || events[1].ErrorReason != string(metav1.StatusReasonAlreadyExists) {
+		t.Errorf("unexpected outcome for failed create: %+v", events[1])
+	}
+	for _, e := range events {
+		if e.APIVersion != "v1" || e.Kind != "ConfigMap" || e.Namespace != "default" || e.Name != "cm" {
+			t.Errorf("expected event to identify v1/ConfigMap default/cm, got %+v", e)
+		}
+	}
+}
+
+func TestAbsentReads(t *testing.T) {
+	sink := NewMemorySink()
+	c := Wrap(fake.NewClientBuilder().Build()).WithName("test-controller").WithOptions(WithSink(sink))
+	ctx := WithReconcileID(context.Background(), "reconcile-1")
+
+	err := c.Get(ctx, client.ObjectKey{Namespace: "default", Name: "missing"}, &corev1.ConfigMap{})
+	if !apierrors.IsNotFound(err) {
+		t.Fatalf("expected NotFound, got %v", err)
+	}
+	if err := c.List(ctx, &corev1.ConfigMapList{}, client.InNamespace("default"), client.MatchingLabels{"app": "web"}); err != nil {
+		t.Fatalf("list failed: %v", err)
+	}
+
+	events, err := sink.Events()
+	if err != nil {
+		t.Fatalf("failed to decode events: %v", err)
+	}
+	if len(events) != 2 {
+		t.Fatalf("expected 2 events, got %d", len(events))
+	}
+	get, list := events[0], events[1]
+	if !get.Absent || get.OpType != string(GET) || get.Kind != "ConfigMap" || get.Namespace != "default" || get.Name != "missing" {
+		t.Errorf("unexpected event for NotFound get: %+v", get)
+	}
+	if !list.Absent || list.OpType != string(LIST) || list.Kind != "ConfigMap" || list.Namespace != "default" || list.LabelSelector != "app=web" {
+		t.Errorf("unexpected event for empty list: %+v", list)
+	}
+}
+
+func TestListObservation(t *testing.T) {
+	objs := []client.Object{
+		&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "a", UID: "uid-a", Namespace: "default", Labels: map[string]string{"app": "web"}}},
+		&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "b", UID: "uid-b", Namespace: "default", Labels: map[string]string{"app": "web"}}},
+		&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "c", UID: "uid-c", Namespace: "default", Labels: map[string]string{"app": "db"}}},
+	}
+	sink := NewMemorySink()
+	c := Wrap(fake.NewClientBuilder().WithObjects(objs...).Build()).WithName("test-controller").WithOptions(WithSink(sink))
+	ctx := WithReconcileID(context.Background(), "reconcile-1")
+
+	list := &corev1.ConfigMapList{}
+	if err := c.List(ctx, list, client.InNamespace("default"), client.MatchingLabels{"app": "web"}, client.Limit(10)); err != nil {
+		t.Fatalf("list failed: %v", err)
+	}
+
+	var observations []event.ListObservation
+	for _, r := range sink.Records() {
+		if r.LogType != tag.ListObservationKey {
+			continue
+		}
+		var o event.ListObservation
+		if err := json.Unmarshal([]byte(r.Payload), &o); err != nil {
+			t.Fatalf("failed to decode list observation: %v", err)
+		}
+		observations = append(observations, o)
+	}
+	if len(observations) != 1 {
+		t.Fatalf("expected 1 list observation, got %d", len(observations))
+	}
+	o := observations[0]
+	if o.Kind != "ConfigMap" || o.APIVersion != "v1" || o.Namespace != "default" || o.LabelSelector != "app=web" || o.Limit != 10 {
+		t.Errorf("unexpected list observation: %+v", o)
+	}
+	if o.ResourceVersion != list.GetResourceVersion() {
+		t.Errorf("expected list resourceVersion %q, got %q", list.GetResourceVersion(), o.ResourceVersion)
+	}
+	if len(o.Items) != 2 || len(o.Observation().Elements()) != 2 {
+		t.Errorf("expected 2 items in the observation, got %+v", o.Items)
+	}
+
+	events, err := sink.Events()
+	if err != nil {
+		t.Fatalf("failed to decode events: %v", err)
+	}
+	for _, e := range events {
+		if e.ListID != o.ListID {
+			t.Errorf("expected LIST event to belong to list %s, got %+v", o.ListID, e)
+		}
+	}
+}
+
+func TestPatchRecording(t *testing.T) {
+	cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "cm", Namespace: "default"}, Data: map[string]string{"scale": "1"}}
+	applied := &corev1.ConfigMap{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "ConfigMap"},
+		ObjectMeta: metav1.ObjectMeta{Name: "cm", Namespace: "default"},
+	}
+
+	tests := []struct {
+		name    string
+		maxSize int
+		patch   func(ctx context.Context, c *Client) error
+		check   func(t *testing.T, e event.Event)
+	}{
+		{
+			name: "merge patch",
+			patch: func(ctx context.Context, c *Client) error {
+				obj := &corev1.ConfigMap{}
+				if err := c.Get(ctx, client.ObjectKeyFromObject(cm), obj); err != nil {
+					return err
+				}
+				base := obj.DeepCopy()
+				obj.Data["scale"] = "2"
+				return c.Patch(ctx, obj, client.MergeFrom(base))
+			},
+			check: func(t *testing.T, e event.Event) {
+				var data map[string]interface{}
+				if err := json.Unmarshal([]byte(e.PatchData), &data); err != nil {
+					t.Fatalf("failed to decode patch data %q: %v", e.PatchData, err)
+				}
+				if e.PatchType != string(types.MergePatchType) || data["data"].(map[string]interface{})["scale"] != "2" {
+					t.Errorf("unexpected patch: %+v", e)
+				}
+				if e.PatchSize != len(e.PatchData) || e.FieldManager != "" {
+					t.Errorf("unexpected patch metadata: %+v", e)
+				}
+			},
+		},
+		{
+			name: "apply patch",
+			patch: func(ctx context.Context, c *Client) error {
+				// the fake client does not support server-side apply, but the patch is recorded before it is sent
+				_ = c.Patch(ctx, applied.DeepCopy(), client.Apply, client.FieldOwner("test-controller"), client.ForceOwnership)
+				return nil
+			},
+			check: func(t *testing.T, e event.Event) {
+				if e.PatchType != string(types.ApplyPatchType) || e.FieldManager != "test-controller" || !e.Force || e.PatchData == "" {
+					t.Errorf("unexpected patch: %+v", e)
+				}
+			},
+		},
+		{
+			name:    "size limit",
+			maxSize: 8,
+			patch: func(ctx context.Context, c *Client) error {
+				obj := &corev1.ConfigMap{}
+				if err := c.Get(ctx, client.ObjectKeyFromObject(cm), obj); err != nil {
+					return err
+				}
+				return c.Patch(ctx, obj, client.RawPatch(types.MergePatchType, []byte(`{"data":{"scale":"3"}}`)))
+			},
+			check: func(t *testing.T, e event.Event) {
+				if e.PatchData != "" || e.PatchSize != len(`{"data":{"scale":"3"}}`) {
+					t.Errorf("expected patch data to be left out, got %+v", e)
+				}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sink := NewMemorySink()
+			c := Wrap(fake.NewClientBuilder().WithObjects(cm.DeepCopy()).Build()).WithName("test-controller").
+				WithOptions(WithSink(sink), LimitPatchSize(tt.maxSize))
+			ctx := WithReconcileID(context.Background(), "reconcile-1")
+			if err := tt.patch(ctx, c); err != nil {
+				t.Fatalf("patch failed: %v", err)
+			}
+
+			events, err := sink.Events()
+			if err != nil {
+				t.Fatalf("failed to decode events: %v", err)
+			}
+			patches := lo.Filter(events, func(e event.Event, _ int) bool { return e.OpType == string(PATCH) })
+			if len(patches) != 1 {
+				t.Fatalf("expected 1 patch event, got %d", len(patches))
+			}
+			tt.check(t, patches[0])
+		})
+	}
+}
@@ -0,0 +1,30 @@
+package client
+
+import "sync"
+
+// logicalClock is a Lamport clock. It ticks on every operation the client traces, and on reads it
+// merges the clock value that the writer of the observed version stamped on the object, so that an
+// operation that causally follows another one always carries a larger clock value.
+type logicalClock struct {
+	time uint64
+	mu   sync.Mutex
+}
+
+// tick advances the clock for a local event and returns the new time.
+func (c *logicalClock) tick() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.time++
+	return c.time
+}
+
+// merge advances the clock past a time observed on another process' write and returns the new time.
+func (c *logicalClock) merge(observed uint64) uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if observed > c.time {
+		c.time = observed
+	}
+	c.time++
+	return c.time
+}
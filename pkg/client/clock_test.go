@@ -0,0 +1,44 @@
+package client
+
+import (
+	"context"
+	"testing"
+
+	"github.com/tgoodwin/sleeve/pkg/tag"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestLogicalClock(t *testing.T) {
+	underlying := fake.NewClientBuilder().Build()
+	writerSink, readerSink := NewMemorySink(), NewMemorySink()
+	writer := Wrap(underlying).WithName("writer").WithOptions(WithSink(writerSink))
+	reader := Wrap(underlying).WithName("reader").WithOptions(WithSink(readerSink))
+
+	// the writer has been busy, so its clock is well ahead of the reader's
+	ctx := WithReconcileID(context.Background(), "reconcile-1")
+	for i := 0; i < 5; i++ {
+		if err := writer.Get(ctx, client.ObjectKey{Namespace: "default", Name: "missing"}, &corev1.ConfigMap{}); !apierrors.IsNotFound(err) {
+			t.Fatalf("expected NotFound, got %v", err)
+		}
+	}
+	cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "cm", Namespace: "default", UID: "uid-a"}}
+	if err := writer.Create(ctx, cm); err != nil {
+		t.Fatalf("create failed: %v", err)
+	}
+	create := lastEvent(t, writerSink)
+	if create.Clock != 6 || tag.GetLogicalClock(cm) != create.Clock {
+		t.Errorf("expected the create to tick the clock to 6 and stamp it, got event clock %d and label %d", create.Clock, tag.GetLogicalClock(cm))
+	}
+
+	ctx = WithReconcileID(context.Background(), "reconcile-2")
+	if err := reader.Get(ctx, client.ObjectKeyFromObject(cm), &corev1.ConfigMap{}); err != nil {
+		t.Fatalf("get failed: %v", err)
+	}
+	if read := lastEvent(t, readerSink); read.Clock <= create.Clock {
+		t.Errorf("read of the created object has clock %d, which does not follow the create's clock %d", read.Clock, create.Clock)
+	}
+}
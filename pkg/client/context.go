@@ -1,11 +1,27 @@
 package client
 
-import "sync"
+import (
+	"container/list"
+	"context"
+	"math/rand"
+	"sync"
 
+	ctrl "sigs.k8s.io/controller-runtime/pkg/controller"
+)
+
+// ReconcileContext holds the trace state that is scoped to a single reconcile invocation.
 type ReconcileContext struct {
 	reconcileID string
 	rootID      string
 
+	// the latest version of each object that the reconcile has observed
+	observed map[string]observedObject
+
+	// the random sources that fault injection policies draw from during this reconcile
+	rngs map[interface{}]*rand.Rand
+	// the number of calls each fault injection policy has matched during this reconcile
+	calls map[interface{}]int
+
 	mu sync.Mutex
 }
 
@@ -32,3 +48,149 @@ func (rc *ReconcileContext) GetRootID() string {
 	defer rc.mu.Unlock()
 	return rc.rootID
 }
+
+func (rc *ReconcileContext) setObserved(key string, obj observedObject) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	if rc.observed == nil {
+		rc.observed = make(map[string]observedObject)
+	}
+	rc.observed[key] = obj
+}
+
+func (rc *ReconcileContext) getObserved(key string) (observedObject, bool) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	obj, ok := rc.observed[key]
+	return obj, ok
+}
+
+func (rc *ReconcileContext) forgetObserved(key string) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	delete(rc.observed, key)
+}
+
+// rng returns the random source that the reconcile uses for the given policy, creating it from seed on first use.
+func (rc *ReconcileContext) rng(policy interface{}, seed int64) *rand.Rand {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	if rc.rngs == nil {
+		rc.rngs = make(map[interface{}]*rand.Rand)
+	}
+	r, ok := rc.rngs[policy]
+	if !ok {
+		r = rand.New(rand.NewSource(seed))
+		rc.rngs[policy] = r
+	}
+	return r
+}
+
+// nextCall returns the index of the reconcile's next call that matches the given policy.
+func (rc *ReconcileContext) nextCall(policy interface{}) int {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	if rc.calls == nil {
+		rc.calls = make(map[interface{}]int)
+	}
+	call := rc.calls[policy]
+	rc.calls[policy]++
+	return call
+}
+
+type reconcileIDKey struct{}
+
+type reconcileContextKey struct{}
+
+// WithReconcileID returns a context that identifies a reconcile invocation for contexts
+// that were not created by controller-runtime (e.g. in tests).
+func WithReconcileID(ctx context.Context, reconcileID string) context.Context {
+	return context.WithValue(ctx, reconcileIDKey{}, reconcileID)
+}
+
+// ReconcileIDFromContext returns the controller-runtime reconcile ID if present,
+// falling back to one set with WithReconcileID.
+func ReconcileIDFromContext(ctx context.Context) string {
+	if rid := string(ctrl.ReconcileIDFromContext(ctx)); rid != "" {
+		return rid
+	}
+	rid, _ := ctx.Value(reconcileIDKey{}).(string)
+	return rid
+}
+
+// withReconcileContext returns a context that carries the trace state for its reconcile invocation,
+// so that the state is released along with the context when the reconcile returns.
+func withReconcileContext(ctx context.Context, rc *ReconcileContext) context.Context {
+	return context.WithValue(ctx, reconcileContextKey{}, rc)
+}
+
+// defaultMaxReconcileContexts bounds the number of reconciles whose trace state a Client keeps
+// when it is not used through WrapReconciler. It is well above the number of reconciles a controller runs concurrently.
+const defaultMaxReconcileContexts = 256
+
+// reconcileContexts tracks the ReconcileContext of every in-flight reconcile, keyed by reconcile ID,
+// so that concurrent reconciles sharing a Client do not clobber each other's state.
+// controller-runtime does not tell the client when a reconcile ends, so once more than limit reconciles
+// are tracked, the state of the least recently used one is released.
+type reconcileContexts struct {
+	limit int
+	byID  map[string]*list.Element
+	// most recently used first
+	order *list.List
+	mu    sync.Mutex
+}
+
+func newReconcileContexts(limit int) *reconcileContexts {
+	if limit <= 0 {
+		limit = defaultMaxReconcileContexts
+	}
+	return &reconcileContexts{limit: limit, byID: make(map[string]*list.Element), order: list.New()}
+}
+
+func (r *reconcileContexts) get(reconcileID string) *ReconcileContext {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if elem, ok := r.byID[reconcileID]; ok {
+		r.order.MoveToFront(elem)
+		return elem.Value.(*ReconcileContext)
+	}
+	rc := &ReconcileContext{reconcileID: reconcileID}
+	r.byID[reconcileID] = r.order.PushFront(rc)
+	r.evict()
+	return rc
+}
+
+// setLimit changes the number of reconciles tracked. A limit of zero or less restores the default.
+func (r *reconcileContexts) setLimit(limit int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if limit <= 0 {
+		limit = defaultMaxReconcileContexts
+	}
+	r.limit = limit
+	r.evict()
+}
+
+// evict releases the least recently used reconciles until at most limit are tracked.
+func (r *reconcileContexts) evict() {
+	for r.order.Len() > r.limit {
+		oldest := r.order.Back()
+		r.order.Remove(oldest)
+		delete(r.byID, oldest.Value.(*ReconcileContext).GetReconcileID())
+	}
+}
+
+func (r *reconcileContexts) remove(reconcileID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if elem, ok := r.byID[reconcileID]; ok {
+		r.order.Remove(elem)
+		delete(r.byID, reconcileID)
+	}
+}
+
+func (r *reconcileContexts) len() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.byID)
+}
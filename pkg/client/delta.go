@@ -0,0 +1,117 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/tgoodwin/sleeve/pkg/event"
+	"github.com/tgoodwin/sleeve/pkg/snapshot"
+	"gomodules.xyz/jsonpatch/v2"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// observedObject is the content of an object as the reconcile last observed it.
+type observedObject struct {
+	version string
+	data    []byte
+}
+
+func observedKey(gk schema.GroupKind, obj client.Object) string {
+	return gk.String() + "/" + historyKey(obj)
+}
+
+// toJSON serializes obj without the fields that snapshot diffs ignore,
+// such as the resourceVersion or the sleeve labels.
+func toJSON(obj client.Object) ([]byte, error) {
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return nil, err
+	}
+	stripIgnored(content)
+	return json.Marshal(content)
+}
+
+// stripIgnored removes the fields that the apiserver and sleeve maintain from the object's content:
+// the ignored metadata fields, the sleeve labels and status.observedGeneration.
+// Fields of the same name elsewhere in the object are user data and are kept.
+func stripIgnored(content map[string]interface{}) {
+	if metadata, ok := content["metadata"].(map[string]interface{}); ok {
+		for k := range metadata {
+			if snapshot.IsIgnoredField(k) {
+				delete(metadata, k)
+			}
+		}
+		if labels, ok := metadata["labels"].(map[string]interface{}); ok {
+			for k := range labels {
+				if snapshot.IsIgnoredField(k) {
+					delete(labels, k)
+				}
+			}
+			if len(labels) == 0 {
+				delete(metadata, "labels")
+			}
+		}
+	}
+	if status, ok := content["status"].(map[string]interface{}); ok {
+		delete(status, "observedGeneration")
+		if len(status) == 0 {
+			delete(content, "status")
+		}
+	}
+}
+
+// observe remembers obj as the latest version of it that the reconcile has seen,
+// either from a read or as the result of a write.
+func (c *Client) observe(ctx context.Context, obj client.Object) {
+	if !c.config.RecordWriteDeltas {
+		return
+	}
+	data, err := toJSON(obj)
+	if err != nil {
+		c.logger.V(1).Error(err, "failed to serialize observed object")
+		return
+	}
+	rc := c.reconcileContext(ctx)
+	rc.setObserved(observedKey(c.groupVersionKindFor(obj).GroupKind(), obj), observedObject{version: obj.GetResourceVersion(), data: data})
+}
+
+// recordDelta records on a write event how the object being written differs from the version of it
+// that the reconcile last observed. Unlike a snapshot of the written object, the delta is not polluted
+// by the fields that the apiserver defaults on the read path.
+func (c *Client) recordDelta(ctx context.Context, e *event.Event, obj client.Object) {
+	if !c.config.RecordWriteDeltas {
+		return
+	}
+	rc := c.reconcileContext(ctx)
+	base, ok := rc.getObserved(observedKey(e.GroupVersionKind().GroupKind(), obj))
+	if !ok {
+		return
+	}
+	current, err := toJSON(obj)
+	if err != nil {
+		c.logger.V(1).Error(err, "failed to serialize written object")
+		return
+	}
+	ops, err := jsonpatch.CreatePatch(base.data, current)
+	if err != nil {
+		c.logger.V(1).Error(err, "failed to compute write delta")
+		return
+	}
+	e.BaseVersion = base.version
+	e.Delta = make([]event.DeltaOp, 0, len(ops))
+	for _, op := range ops {
+		e.Delta = append(e.Delta, event.DeltaOp{Op: op.Operation, Path: op.Path, Value: op.Value})
+	}
+}
+
+// observeWrite updates the reconcile's view of an object after a successful write.
+func (c *Client) observeWrite(ctx context.Context, e *event.Event, obj client.Object, op OperationType) {
+	switch op {
+	case DELETE:
+		c.reconcileContext(ctx).forgetObserved(observedKey(e.GroupVersionKind().GroupKind(), obj))
+	case CREATE, UPDATE, PATCH:
+		c.observe(ctx, obj)
+	}
+}
@@ -0,0 +1,107 @@
+package client
+
+import (
+	"context"
+	"testing"
+
+	"github.com/tgoodwin/sleeve/pkg/event"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestWriteDelta(t *testing.T) {
+	cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "cm", Namespace: "default"}, Data: map[string]string{"scale": "1"}}
+	sink := NewMemorySink()
+	c := Wrap(fake.NewClientBuilder().WithObjects(cm).Build()).WithName("test-controller").WithOptions(WithSink(sink), RecordWriteDeltas())
+	ctx := WithReconcileID(context.Background(), "reconcile-1")
+
+	obj := &corev1.ConfigMap{}
+	if err := c.Get(ctx, client.ObjectKeyFromObject(cm), obj); err != nil {
+		t.Fatalf("get failed: %v", err)
+	}
+	observedVersion := obj.GetResourceVersion()
+	obj.Data["scale"] = "2"
+	if err := c.Update(ctx, obj); err != nil {
+		t.Fatalf("update failed: %v", err)
+	}
+	// the second write is diffed against the result of the first
+	base := obj.DeepCopy()
+	obj.Data["mode"] = "fast"
+	if err := c.Patch(ctx, obj, client.MergeFrom(base)); err != nil {
+		t.Fatalf("patch failed: %v", err)
+	}
+
+	e := lastEvent(t, sink)
+	events, err := sink.Events()
+	if err != nil {
+		t.Fatalf("failed to decode events: %v", err)
+	}
+	update, patch := events[1], e
+
+	want := []event.DeltaOp{{Op: "replace", Path: "/data/scale", Value: "2"}}
+	if update.BaseVersion != observedVersion || !equalDelta(update.Delta, want) {
+		t.Errorf("unexpected update delta against %s: %+v", update.BaseVersion, update.Delta)
+	}
+	want = []event.DeltaOp{{Op: "add", Path: "/data/mode", Value: "fast"}}
+	if patch.BaseVersion != update.ResultVersion || !equalDelta(patch.Delta, want) {
+		t.Errorf("unexpected patch delta against %s: %+v", patch.BaseVersion, patch.Delta)
+	}
+}
+
+func equalDelta(a, b []event.DeltaOp) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestWriteDeltaKeepsUserFieldsNamedLikeIgnoredOnes(t *testing.T) {
+	cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "cm", Namespace: "default"}, Data: map[string]string{"generation": "1"}}
+	sink := NewMemorySink()
+	c := Wrap(fake.NewClientBuilder().WithObjects(cm).Build()).WithName("test-controller").WithOptions(WithSink(sink), RecordWriteDeltas())
+	ctx := WithReconcileID(context.Background(), "reconcile-1")
+
+	obj := &corev1.ConfigMap{}
+	if err := c.Get(ctx, client.ObjectKeyFromObject(cm), obj); err != nil {
+		t.Fatalf("get failed: %v", err)
+	}
+	obj.Data["generation"] = "2"
+	if err := c.Update(ctx, obj); err != nil {
+		t.Fatalf("update failed: %v", err)
+	}
+
+	want := []event.DeltaOp{{Op: "replace", Path: "/data/generation", Value: "2"}}
+	if e := lastEvent(t, sink); !equalDelta(e.Delta, want) {
+		t.Errorf("unexpected delta: %+v", e.Delta)
+	}
+}
+
+func TestWriteDeltasAreOptIn(t *testing.T) {
+	cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "cm", Namespace: "default"}, Data: map[string]string{"scale": "1"}}
+	sink := NewMemorySink()
+	c := Wrap(fake.NewClientBuilder().WithObjects(cm).Build()).WithName("test-controller").WithOptions(WithSink(sink))
+	ctx := WithReconcileID(context.Background(), "reconcile-1")
+
+	obj := &corev1.ConfigMap{}
+	if err := c.Get(ctx, client.ObjectKeyFromObject(cm), obj); err != nil {
+		t.Fatalf("get failed: %v", err)
+	}
+	if _, ok := c.reconcileContext(ctx).getObserved(observedKey(schema.GroupKind{Kind: "ConfigMap"}, obj)); ok {
+		t.Errorf("expected observed objects not to be kept unless deltas are recorded")
+	}
+	obj.Data["scale"] = "2"
+	if err := c.Update(ctx, obj); err != nil {
+		t.Fatalf("update failed: %v", err)
+	}
+	if e := lastEvent(t, sink); e.Delta != nil || e.BaseVersion != "" {
+		t.Errorf("expected no delta to be recorded, got %+v against %q", e.Delta, e.BaseVersion)
+	}
+}
@@ -0,0 +1,79 @@
+package client
+
+import (
+	"reflect"
+	"time"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+type observedVersion struct {
+	obj client.Object
+
+	// when the client first observed this version
+	observedAt time.Time
+
+	// best estimate of when this version was written to the apiserver
+	writtenAt time.Time
+}
+
+// versionHistory retains the most recent versions of each object that the client has observed.
+// It is not safe for concurrent use; callers hold their own lock.
+type versionHistory struct {
+	size     int
+	byObject map[string][]observedVersion
+}
+
+func newVersionHistory(size int) *versionHistory {
+	return &versionHistory{size: size, byObject: make(map[string][]observedVersion)}
+}
+
+func historyKey(obj client.Object) string {
+	if uid := obj.GetUID(); uid != "" {
+		return string(uid)
+	}
+	return obj.GetNamespace() + "/" + obj.GetName()
+}
+
+// record appends obj to its object's history if its resourceVersion is new, and returns the history
+// ordered from oldest to newest.
+func (h *versionHistory) record(obj client.Object, now time.Time) []observedVersion {
+	key := historyKey(obj)
+	versions := h.byObject[key]
+	if len(versions) == 0 || versions[len(versions)-1].obj.GetResourceVersion() != obj.GetResourceVersion() {
+		versions = append(versions, observedVersion{
+			obj:        obj.DeepCopyObject().(client.Object),
+			observedAt: now,
+			writtenAt:  writeTime(obj, len(versions) == 0, now),
+		})
+		if len(versions) > h.size {
+			versions = versions[len(versions)-h.size:]
+		}
+		h.byObject[key] = versions
+	}
+	return versions
+}
+
+// writeTime estimates when the given version of obj was written. The apiserver stamps every write
+// in managedFields; without them, the first version we see is assumed to be the one that was created,
+// and any later version is assumed to have been written when we first observed it.
+func writeTime(obj client.Object, first bool, now time.Time) time.Time {
+	var latest time.Time
+	for _, mf := range obj.GetManagedFields() {
+		if mf.Time != nil && mf.Time.After(latest) {
+			latest = mf.Time.Time
+		}
+	}
+	if !latest.IsZero() {
+		return latest
+	}
+	if first {
+		return obj.GetCreationTimestamp().Time
+	}
+	return now
+}
+
+// setObject overwrites the object that dst points to with a copy of src, which must be of the same type.
+func setObject(dst, src client.Object) {
+	reflect.ValueOf(dst).Elem().Set(reflect.ValueOf(src.DeepCopyObject()).Elem())
+}
@@ -0,0 +1,178 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"hash/fnv"
+	"math/rand"
+	"strings"
+
+	"github.com/tgoodwin/sleeve/pkg/event"
+	"github.com/tgoodwin/sleeve/pkg/util"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// AllKinds can be passed to InjectErrors to apply a policy to every kind
+// that does not have a policy of its own.
+var AllKinds = schema.GroupKind{Group: "*", Kind: "*"}
+
+// APIError is a synthetic error that the client can return in place of calling the apiserver.
+type APIError string
+
+const (
+	ErrConflict        APIError = "Conflict"
+	ErrTooManyRequests APIError = "TooManyRequests"
+	ErrInternal        APIError = "InternalError"
+	ErrTimeout         APIError = "Timeout"
+	ErrNotFound        APIError = "NotFound"
+)
+
+var errInjected = errors.New("injected by sleeve")
+
+func (e APIError) toError(gr schema.GroupResource, name string) error {
+	switch e {
+	case ErrConflict:
+		return apierrors.NewConflict(gr, name, errInjected)
+	case ErrTooManyRequests:
+		return apierrors.NewTooManyRequests(errInjected.Error(), 1)
+	case ErrTimeout:
+		return apierrors.NewTimeoutError(errInjected.Error(), 1)
+	case ErrNotFound:
+		return apierrors.NewNotFound(gr, name)
+	default:
+		return apierrors.NewInternalError(errInjected)
+	}
+}
+
+// ErrorPolicy decides which operations on a kind fail with a synthetic API error.
+type ErrorPolicy struct {
+	// Ops restricts injection to the given operation types. Empty means all operations.
+	Ops []OperationType
+
+	// Errors to inject. When more than one is given, each injection picks one at random.
+	// Defaults to ErrInternal.
+	Errors []APIError
+
+	// Seed for the random source that decides which calls fail and with which error.
+	// Each reconcile draws from its own source, derived from the seed and its reconcile ID,
+	// so the calls that fail within a reconcile do not depend on how it interleaves with others.
+	Seed int64
+
+	// Probability that a matching call fails.
+	Probability float64
+
+	// Schedule lists the (0-based) indices of the matching calls that fail.
+	// Calls are counted per reconcile, so the same calls fail however reconciles interleave.
+	// If set, it is used instead of Probability.
+	Schedule []int
+}
+
+// errorInjection holds the per-kind state needed to apply an ErrorPolicy.
+type errorInjection struct {
+	policy   ErrorPolicy
+	ops      util.Set[OperationType]
+	schedule util.Set[int]
+}
+
+func newErrorInjection(policy ErrorPolicy) *errorInjection {
+	if len(policy.Errors) == 0 {
+		policy.Errors = []APIError{ErrInternal}
+	}
+	inj := &errorInjection{
+		policy:   policy,
+		ops:      util.NewSet[OperationType](),
+		schedule: util.NewSet[int](),
+	}
+	for _, op := range policy.Ops {
+		inj.ops.Add(op)
+	}
+	for _, i := range policy.Schedule {
+		inj.schedule.Add(i)
+	}
+	return inj
+}
+
+func InjectErrors(gk schema.GroupKind, policy ErrorPolicy) Option {
+	return func(o *Config) {
+		if o.errorsByKind == nil {
+			o.errorsByKind = make(map[schema.GroupKind]*errorInjection)
+		}
+		o.errorsByKind[gk] = newErrorInjection(policy)
+	}
+}
+
+// rngFor returns the random source that the given reconcile draws from.
+func (inj *errorInjection) rngFor(rc *ReconcileContext) *rand.Rand {
+	h := fnv.New64a()
+	h.Write([]byte(rc.GetReconcileID()))
+	return rc.rng(inj, inj.policy.Seed^int64(h.Sum64()))
+}
+
+// next decides whether the next call of the given operation type fails, and with which error.
+func (inj *errorInjection) next(rc *ReconcileContext, op OperationType) (APIError, bool) {
+	if len(inj.ops) > 0 {
+		if _, ok := inj.ops[op]; !ok {
+			return "", false
+		}
+	}
+
+	call := rc.nextCall(inj)
+	rng := inj.rngFor(rc)
+
+	if len(inj.schedule) > 0 {
+		if _, ok := inj.schedule[call]; !ok {
+			return "", false
+		}
+	} else if rng.Float64() >= inj.policy.Probability {
+		return "", false
+	}
+	return inj.policy.Errors[rng.Intn(len(inj.policy.Errors))], true
+}
+
+// injectError returns a synthetic API error for the operation if an error injection policy applies to it.
+// Every injected error is recorded as a fault.
+func (c *Client) injectError(ctx context.Context, gvk schema.GroupVersionKind, key client.ObjectKey, op OperationType) error {
+	inj, ok := c.config.errorsByKind[gvk.GroupKind()]
+	if !ok {
+		if inj, ok = c.config.errorsByKind[AllKinds]; !ok {
+			return nil
+		}
+	}
+	rc := c.reconcileContext(ctx)
+	apiErr, ok := inj.next(rc, op)
+	if !ok {
+		return nil
+	}
+	c.logFault(rc, &event.Fault{
+		FaultType:  event.FaultAPIError,
+		OpType:     string(op),
+		Kind:       gvk.Kind,
+		APIVersion: gvk.GroupVersion().String(),
+		Namespace:  key.Namespace,
+		Name:       key.Name,
+		Detail:     string(apiErr),
+	})
+	return apiErr.toError(c.groupResourceFor(gvk), key.Name)
+}
+
+// groupResourceFor returns the resource that serves the given kind, as the apiserver would name it in an error.
+// Kinds that the RESTMapper does not know are assumed to follow the usual lowercase plural naming.
+func (c *Client) groupResourceFor(gvk schema.GroupVersionKind) schema.GroupResource {
+	if mapper := c.RESTMapper(); mapper != nil {
+		if mapping, err := mapper.RESTMapping(gvk.GroupKind(), gvk.Version); err == nil {
+			return mapping.Resource.GroupResource()
+		}
+	}
+	plural, _ := meta.UnsafeGuessKindToResource(gvk)
+	return plural.GroupResource()
+}
+
+// listItemGVK returns the GroupVersionKind of the items in the list.
+func (c *Client) listItemGVK(list client.ObjectList) schema.GroupVersionKind {
+	gvk := c.groupVersionKindFor(list)
+	gvk.Kind = strings.TrimSuffix(gvk.Kind, "List")
+	return gvk
+}
@@ -0,0 +1,158 @@
+package client
+
+import (
+	"context"
+	"testing"
+
+	"github.com/tgoodwin/sleeve/pkg/event"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestInjectErrors(t *testing.T) {
+	cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "cm", Namespace: "default"}}
+	sink := NewMemorySink()
+	c := Wrap(fake.NewClientBuilder().WithObjects(cm).Build()).
+		WithName("test-controller").
+		WithOptions(
+			WithSink(sink),
+			InjectErrors(schema.GroupKind{Kind: "ConfigMap"}, ErrorPolicy{
+				Ops:      []OperationType{UPDATE},
+				Errors:   []APIError{ErrConflict},
+				Schedule: []int{1},
+			}),
+		)
+	ctx := WithReconcileID(context.Background(), "reconcile-1")
+
+	obj := &corev1.ConfigMap{}
+	if err := c.Get(ctx, client.ObjectKeyFromObject(cm), obj); err != nil {
+		t.Fatalf("get failed: %v", err)
+	}
+	if err := c.Update(ctx, obj); err != nil {
+		t.Fatalf("first update should not be injected: %v", err)
+	}
+	err := c.Update(ctx, obj)
+	if !apierrors.IsConflict(err) {
+		t.Fatalf("expected injected conflict on second update, got %v", err)
+	}
+	if details := err.(apierrors.APIStatus).Status().Details; details.Group != "" || details.Kind != "configmaps" {
+		t.Errorf("expected the conflict to name the configmaps resource, got %+v", details)
+	}
+	if err := c.Update(ctx, obj); err != nil {
+		t.Fatalf("third update should not be injected: %v", err)
+	}
+
+	fs := faults(t, sink)
+	if len(fs) != 1 {
+		t.Fatalf("expected 1 fault record, got %d", len(fs))
+	}
+	if fs[0].FaultType != event.FaultAPIError || fs[0].OpType != string(UPDATE) || fs[0].Detail != string(ErrConflict) || fs[0].Name != "cm" {
+		t.Errorf("unexpected fault record: %+v", fs[0])
+	}
+
+	events, err := sink.Events()
+	if err != nil {
+		t.Fatalf("failed to decode events: %v", err)
+	}
+	failed := 0
+	for _, e := range events {
+		if e.Failed() {
+			failed++
+			if e.ErrorReason != string(metav1.StatusReasonConflict) {
+				t.Errorf("failed event has reason %q", e.ErrorReason)
+			}
+		}
+	}
+	if failed != 1 {
+		t.Errorf("expected 1 failed write event, got %d", failed)
+	}
+}
+
+func TestInjectedErrorsNameTheResource(t *testing.T) {
+	c := Wrap(fake.NewClientBuilder().Build()).
+		WithName("test-controller").
+		WithOptions(WithSink(NewMemorySink()), InjectErrors(schema.GroupKind{Group: "apps", Kind: "Deployment"}, ErrorPolicy{Errors: []APIError{ErrNotFound}, Probability: 1}))
+	ctx := WithReconcileID(context.Background(), "reconcile-1")
+
+	err := c.Get(ctx, client.ObjectKey{Namespace: "default", Name: "deploy"}, &appsv1.Deployment{})
+	if !apierrors.IsNotFound(err) {
+		t.Fatalf("expected injected NotFound, got %v", err)
+	}
+	if details := err.(apierrors.APIStatus).Status().Details; details.Group != "apps" || details.Kind != "deployments" || details.Name != "deploy" {
+		t.Errorf("expected the error to name deployments.apps, got %+v", details)
+	}
+}
+
+func TestInjectedErrorsAreReproduciblePerReconcile(t *testing.T) {
+	cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "cm", Namespace: "default"}}
+	policy := ErrorPolicy{Ops: []OperationType{GET}, Seed: 7, Probability: 0.5}
+
+	// outcomes returns which of n reads in reconcile-a fail, with each read in reconcile-a
+	// preceded by the given number of reads in reconcile-b
+	outcomes := func(interleaved int) []bool {
+		c := Wrap(fake.NewClientBuilder().WithObjects(cm).Build()).
+			WithName("test-controller").
+			WithOptions(WithSink(NewMemorySink()), InjectErrors(schema.GroupKind{Kind: "ConfigMap"}, policy))
+		a := WithReconcileID(context.Background(), "reconcile-a")
+		b := WithReconcileID(context.Background(), "reconcile-b")
+		out := make([]bool, 0, 20)
+		for i := 0; i < 20; i++ {
+			for j := 0; j < interleaved; j++ {
+				_ = c.Get(b, client.ObjectKeyFromObject(cm), &corev1.ConfigMap{})
+			}
+			out = append(out, c.Get(a, client.ObjectKeyFromObject(cm), &corev1.ConfigMap{}) != nil)
+		}
+		return out
+	}
+
+	alone, interleaved := outcomes(0), outcomes(3)
+	failed := 0
+	for i := range alone {
+		if alone[i] != interleaved[i] {
+			t.Fatalf("read %d of reconcile-a failed=%v alone but failed=%v when interleaved", i, alone[i], interleaved[i])
+		}
+		if alone[i] {
+			failed++
+		}
+	}
+	if failed == 0 || failed == len(alone) {
+		t.Errorf("expected some but not all reads to fail, %d of %d failed", failed, len(alone))
+	}
+}
+
+func TestInjectScheduleCountsCallsPerReconcile(t *testing.T) {
+	cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "cm", Namespace: "default"}}
+	c := Wrap(fake.NewClientBuilder().WithObjects(cm).Build()).
+		WithName("test-controller").
+		WithOptions(WithSink(NewMemorySink()), InjectErrors(schema.GroupKind{Kind: "ConfigMap"}, ErrorPolicy{
+			Ops:      []OperationType{GET},
+			Schedule: []int{1},
+		}))
+	a := WithReconcileID(context.Background(), "reconcile-a")
+	b := WithReconcileID(context.Background(), "reconcile-b")
+
+	// the reads of the two reconciles alternate, so a client-wide count would fail b's first read
+	for i, ctx := range []context.Context{a, b, a, b, a, b} {
+		err := c.Get(ctx, client.ObjectKeyFromObject(cm), &corev1.ConfigMap{})
+		if want := i/2 == 1; (err != nil) != want {
+			t.Errorf("read %d of %s: expected failure=%v, got %v", i/2, ReconcileIDFromContext(ctx), want, err)
+		}
+	}
+}
+
+func TestInjectErrorsMatchesGroup(t *testing.T) {
+	cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "cm", Namespace: "default"}}
+	c := Wrap(fake.NewClientBuilder().WithObjects(cm).Build()).
+		WithName("test-controller").
+		WithOptions(WithSink(NewMemorySink()), InjectErrors(schema.GroupKind{Group: "example.com", Kind: "ConfigMap"}, ErrorPolicy{Probability: 1}))
+	ctx := WithReconcileID(context.Background(), "reconcile-1")
+
+	if err := c.Get(ctx, client.ObjectKeyFromObject(cm), &corev1.ConfigMap{}); err != nil {
+		t.Errorf("a policy for example.com ConfigMaps must not apply to core ConfigMaps: %v", err)
+	}
+}
@@ -1,16 +1,44 @@
 package client
 
-import "time"
+import (
+	"time"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
 
 type Config struct {
-	LogObjectSnapshots    bool
-	visibilityDelayByKind map[string]time.Duration
+	LogObjectSnapshots bool
+	visibilityByKind   map[schema.GroupKind]*visibilityLag
+	// delays set through the deprecated VisibilityDelay, which apply to a kind in any group
+	visibilityByKindName map[string]*visibilityLag
+	staleReadsByKind     map[schema.GroupKind]*staleReads
+	errorsByKind         map[schema.GroupKind]*errorInjection
+
+	// RecordWriteDeltas records on every Update and Patch how the written object differs from the version
+	// the reconcile last observed. This keeps a serialized copy of every object a reconcile reads.
+	RecordWriteDeltas bool
+
+	// Sink receives every trace record the client emits.
+	// If nil, records are written to the sleeve logr logger.
+	Sink TraceSink
+
+	// MaxReconcileContexts bounds the number of reconciles whose trace state the Client keeps.
+	// Zero means the default of 256.
+	MaxReconcileContexts int
+
+	// MaxPatchSize is the size in bytes above which patch data is left out of the trace.
+	// Zero means no limit.
+	MaxPatchSize int
 }
 
 func NewConfig() *Config {
 	return &Config{
-		LogObjectSnapshots:    true,
-		visibilityDelayByKind: make(map[string]time.Duration),
+		LogObjectSnapshots:   true,
+		visibilityByKind:     make(map[schema.GroupKind]*visibilityLag),
+		visibilityByKindName: make(map[string]*visibilityLag),
+		staleReadsByKind:     make(map[schema.GroupKind]*staleReads),
+		errorsByKind:         make(map[schema.GroupKind]*errorInjection),
+		Sink:                 NewLogrSink(log),
 	}
 }
 
@@ -22,12 +50,56 @@ func LogObjectSnapshots() Option {
 	}
 }
 
+// RecordWriteDeltas records the delta of every write against the version of the object that the reconcile last observed.
+func RecordWriteDeltas() Option {
+	return func(o *Config) {
+		o.RecordWriteDeltas = true
+	}
+}
+
+// VisibilityDelay models informer cache lag for the named kind in any group.
+//
+// Deprecated: use VisibilityDelayFor, which tells kinds of the same name in different groups apart.
+// A delay set with VisibilityDelayFor takes precedence over one set for the same kind with VisibilityDelay.
 func VisibilityDelay(kind string, duration time.Duration) Option {
 	return func(o *Config) {
-		if o.visibilityDelayByKind == nil {
-			o.visibilityDelayByKind = make(map[string]time.Duration)
+		if o.visibilityByKindName == nil {
+			o.visibilityByKindName = make(map[string]*visibilityLag)
+		}
+		o.visibilityByKindName[kind] = newVisibilityLag(duration)
+	}
+}
+
+// VisibilityDelayFor models informer cache lag for a kind: a newly created object is hidden,
+// and a newly written version is replaced by the previous one, until duration has passed since the write.
+func VisibilityDelayFor(gk schema.GroupKind, duration time.Duration) Option {
+	return func(o *Config) {
+		if o.visibilityByKind == nil {
+			o.visibilityByKind = make(map[schema.GroupKind]*visibilityLag)
 		}
-		o.visibilityDelayByKind[kind] = duration
+		o.visibilityByKind[gk] = newVisibilityLag(duration)
+	}
+}
+
+// LimitPatchSize leaves patches larger than maxBytes out of the trace.
+func LimitPatchSize(maxBytes int) Option {
+	return func(o *Config) {
+		o.MaxPatchSize = maxBytes
+	}
+}
+
+func WithSink(sink TraceSink) Option {
+	return func(o *Config) {
+		o.Sink = sink
+	}
+}
+
+// MaxTrackedReconciles bounds the number of reconciles whose trace state the Client keeps
+// for reconcilers that are not wrapped with WrapReconciler. It should exceed the number of
+// reconciles that share the Client concurrently.
+func MaxTrackedReconciles(n int) Option {
+	return func(o *Config) {
+		o.MaxReconcileContexts = n
 	}
 }
 
@@ -38,5 +110,6 @@ func (c *Client) WithOptions(opts ...Option) *Client {
 	for _, opt := range opts {
 		opt(c.config)
 	}
+	c.reconcileContexts.setLimit(c.config.MaxReconcileContexts)
 	return c
 }
@@ -0,0 +1,91 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/tgoodwin/sleeve/pkg/event"
+	"github.com/tgoodwin/sleeve/pkg/tag"
+	"github.com/tgoodwin/sleeve/pkg/util"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// Reconciler wraps a reconcile.Reconciler and records the beginning and end of every invocation,
+// so that replay can recover the exact reconcile.Request and result of each reconcile.
+type Reconciler struct {
+	reconciler reconcile.Reconciler
+
+	// identifier for the reconciler. It should match the name given to the wrapped Client.
+	id string
+
+	logger logr.Logger
+	config *Config
+}
+
+var _ reconcile.Reconciler = (*Reconciler)(nil)
+
+func WrapReconciler(name string, r reconcile.Reconciler, opts ...Option) *Reconciler {
+	config := NewConfig()
+	for _, opt := range opts {
+		opt(config)
+	}
+	return &Reconciler{
+		reconciler: r,
+		id:         name,
+		logger:     log,
+		config:     config,
+	}
+}
+
+// Reconcile invokes the wrapped reconciler. If the context was not created by controller-runtime,
+// a reconcile ID is generated for it. The trace state for the invocation travels with the context,
+// so Clients used by the wrapped reconciler do not need EndReconcile to be called.
+func (r *Reconciler) Reconcile(ctx context.Context, req reconcile.Request) (reconcile.Result, error) {
+	rid := ReconcileIDFromContext(ctx)
+	if rid == "" {
+		rid = util.UUID()
+		ctx = WithReconcileID(ctx, rid)
+	}
+	ctx = withReconcileContext(ctx, &ReconcileContext{reconcileID: rid})
+
+	start := time.Now()
+	r.logReconcile(&event.Reconcile{
+		Timestamp:   event.FormatTimeStr(start),
+		ReconcileID: rid,
+		Phase:       event.ReconcileBegin,
+		Namespace:   req.Namespace,
+		Name:        req.Name,
+	})
+
+	res, err := r.reconciler.Reconcile(ctx, req)
+
+	end := &event.Reconcile{
+		Timestamp:   event.FormatTimeStr(time.Now()),
+		ReconcileID: rid,
+		Phase:       event.ReconcileEnd,
+		Namespace:   req.Namespace,
+		Name:        req.Name,
+		Requeue:     res.Requeue,
+		Duration:    time.Since(start).String(),
+	}
+	if res.RequeueAfter > 0 {
+		end.RequeueAfter = res.RequeueAfter.String()
+	}
+	if err != nil {
+		end.Error = err.Error()
+	}
+	r.logReconcile(end)
+
+	return res, err
+}
+
+func (r *Reconciler) logReconcile(rec *event.Reconcile) {
+	rec.ControllerID = r.id
+	payload, err := json.Marshal(rec)
+	if err != nil {
+		panic("failed to marshal reconcile record")
+	}
+	Emit(r.config.Sink, r.logger, tag.ReconcileKey, string(payload))
+}
@@ -0,0 +1,53 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/tgoodwin/sleeve/pkg/event"
+	"github.com/tgoodwin/sleeve/pkg/tag"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func TestWrapReconciler(t *testing.T) {
+	sink := NewMemorySink()
+	var seenID string
+	r := WrapReconciler("test-controller", reconcile.Func(func(ctx context.Context, req reconcile.Request) (reconcile.Result, error) {
+		seenID = ReconcileIDFromContext(ctx)
+		return reconcile.Result{RequeueAfter: time.Minute}, errors.New("boom")
+	}), WithSink(sink))
+
+	req := reconcile.Request{NamespacedName: types.NamespacedName{Namespace: "default", Name: "foo"}}
+	if _, err := r.Reconcile(context.Background(), req); err == nil {
+		t.Fatalf("expected the reconciler's error to be returned")
+	}
+
+	var recs []event.Reconcile
+	for _, rec := range sink.Records() {
+		if rec.LogType != tag.ReconcileKey {
+			continue
+		}
+		var e event.Reconcile
+		if err := json.Unmarshal([]byte(rec.Payload), &e); err != nil {
+			t.Fatalf("failed to decode reconcile record: %v", err)
+		}
+		recs = append(recs, e)
+	}
+	if len(recs) != 2 {
+		t.Fatalf("expected begin and end records, got %d", len(recs))
+	}
+	begin, end := recs[0], recs[1]
+	if begin.Phase != event.ReconcileBegin || begin.Namespace != "default" || begin.Name != "foo" {
+		t.Errorf("unexpected begin record: %+v", begin)
+	}
+	if seenID == "" || begin.ReconcileID != seenID || end.ReconcileID != seenID {
+		t.Errorf("expected records to carry reconcile ID %q, got %q and %q", seenID, begin.ReconcileID, end.ReconcileID)
+	}
+	if end.Phase != event.ReconcileEnd || end.RequeueAfter != "1m0s" || end.Error != "boom" || end.Duration == "" {
+		t.Errorf("unexpected end record: %+v", end)
+	}
+}
@@ -0,0 +1,225 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/tgoodwin/sleeve/pkg/event"
+	"github.com/tgoodwin/sleeve/pkg/tag"
+	"github.com/tgoodwin/sleeve/pkg/trace"
+	"github.com/tgoodwin/sleeve/pkg/util"
+)
+
+// TraceRecord is a single entry in a sleeve trace. LogType is one of the tag.*Key constants
+// and Payload is the JSON-encoded record (an event.Event, a snapshot.Record, etc.)
+type TraceRecord struct {
+	LogType string
+	Payload string
+}
+
+// TraceSink is the destination for the records that the Client emits.
+type TraceSink interface {
+	Emit(r TraceRecord) error
+}
+
+// processID identifies this process instance in the records it emits.
+var processID = util.UUID()
+
+// the sequence number of the last record this process emitted
+var lastSeq atomic.Uint64
+
+// Emit stamps a record with this process' ID and its next sequence number and writes it to the sink,
+// falling back to the logger if no sink is configured. A record that fails to be written leaves a gap in the sequence.
+// The sequence spans every sink in the process, so a complete trace holds the records of all of them.
+// Records are handed to the sink without holding any process-wide lock, so a slow sink only holds up
+// its own caller; records emitted concurrently may reach a sink out of sequence.
+func Emit(sink TraceSink, logger logr.Logger, logType, payload string) {
+	if sink == nil {
+		sink = NewLogrSink(logger)
+	}
+	payload = trace.Stamp{ProcessID: processID, Seq: lastSeq.Add(1)}.Apply(payload)
+	if err := sink.Emit(TraceRecord{LogType: logType, Payload: payload}); err != nil {
+		logger.Error(err, "failed to emit trace record", "LogType", logType)
+	}
+}
+
+// LogrSink writes records through a logr.Logger. This is the default sink and produces
+// the same output that the replay and analysis tooling parse from controller logs.
+type LogrSink struct {
+	logger logr.Logger
+}
+
+func NewLogrSink(logger logr.Logger) *LogrSink {
+	return &LogrSink{logger: logger}
+}
+
+func (s *LogrSink) Emit(r TraceRecord) error {
+	s.logger.WithValues("LogType", r.LogType).Info(r.Payload)
+	return nil
+}
+
+// MemorySink keeps every record in memory. It is intended for tests.
+type MemorySink struct {
+	records []TraceRecord
+	mu      sync.Mutex
+}
+
+func NewMemorySink() *MemorySink {
+	return &MemorySink{}
+}
+
+func (s *MemorySink) Emit(r TraceRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records = append(s.records, r)
+	return nil
+}
+
+// Records returns a copy of the records emitted so far.
+func (s *MemorySink) Records() []TraceRecord {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]TraceRecord, len(s.records))
+	copy(out, s.records)
+	return out
+}
+
+// Events decodes the controller operation records emitted so far.
+func (s *MemorySink) Events() ([]event.Event, error) {
+	events := make([]event.Event, 0)
+	for _, r := range s.Records() {
+		if r.LogType != tag.ControllerOperationKey {
+			continue
+		}
+		var e event.Event
+		if err := e.UnmarshalJSON([]byte(r.Payload)); err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+	return events, nil
+}
+
+// ChannelSink forwards every record to a channel. Emit blocks until the
+// record is received, so the consumer must keep up with the controller.
+type ChannelSink struct {
+	ch chan<- TraceRecord
+}
+
+func NewChannelSink(ch chan<- TraceRecord) *ChannelSink {
+	return &ChannelSink{ch: ch}
+}
+
+func (s *ChannelSink) Emit(r TraceRecord) error {
+	s.ch <- r
+	return nil
+}
+
+// fileLine mirrors the shape of a line produced by zap's JSON encoder
+// so that files written by a FileSink look like controller-runtime JSON logs.
+type fileLine struct {
+	Timestamp string `json:"ts"`
+	Logger    string `json:"logger"`
+	Msg       string `json:"msg"`
+	LogType   string `json:"LogType"`
+}
+
+// FileSink appends records to a JSONL file, rotating it once it grows past maxBytes.
+// Rotated files are renamed to path.1, path.2, ... and at most maxBackups are kept.
+type FileSink struct {
+	path       string
+	maxBytes   int64
+	maxBackups int
+
+	f    *os.File
+	size int64
+	mu   sync.Mutex
+}
+
+func NewFileSink(path string, maxBytes int64, maxBackups int) (*FileSink, error) {
+	s := &FileSink{path: path, maxBytes: maxBytes, maxBackups: maxBackups}
+	if err := s.open(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *FileSink) open() error {
+	f, err := os.OpenFile(s.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("opening trace file: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("opening trace file: %w", err)
+	}
+	s.f = f
+	s.size = info.Size()
+	return nil
+}
+
+// rotate moves the current file aside and starts a new one. If the file cannot be moved aside,
+// the current file is reopened so that the sink keeps working and rotation is retried on the next record.
+func (s *FileSink) rotate() error {
+	err := s.f.Close()
+	if err == nil {
+		err = s.shift()
+	}
+	if openErr := s.open(); openErr != nil {
+		return errors.Join(err, openErr)
+	}
+	return err
+}
+
+// shift renames path.1, path.2, ... up by one, dropping the oldest, and moves path to path.1.
+func (s *FileSink) shift() error {
+	if s.maxBackups <= 0 {
+		return os.Remove(s.path)
+	}
+	for i := s.maxBackups - 1; i > 0; i-- {
+		from := fmt.Sprintf("%s.%d", s.path, i)
+		if _, err := os.Stat(from); err == nil {
+			if err := os.Rename(from, fmt.Sprintf("%s.%d", s.path, i+1)); err != nil {
+				return err
+			}
+		}
+	}
+	return os.Rename(s.path, s.path+".1")
+}
+
+func (s *FileSink) Emit(r TraceRecord) error {
+	line, err := json.Marshal(fileLine{
+		Timestamp: time.Now().Format(time.RFC3339Nano),
+		Logger:    tag.LoggerName,
+		Msg:       r.Payload,
+		LogType:   r.LogType,
+	})
+	if err != nil {
+		return fmt.Errorf("marshaling trace record: %w", err)
+	}
+	line = append(line, '\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.maxBytes > 0 && s.size > 0 && s.size+int64(len(line)) > s.maxBytes {
+		if err := s.rotate(); err != nil {
+			return fmt.Errorf("rotating trace file: %w", err)
+		}
+	}
+	n, err := s.f.Write(line)
+	s.size += int64(n)
+	return err
+}
+
+func (s *FileSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.f.Close()
+}
@@ -0,0 +1,163 @@
+package client
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr/funcr"
+	"github.com/tgoodwin/sleeve/pkg/tag"
+	"github.com/tgoodwin/sleeve/pkg/trace"
+)
+
+func TestFileSinkRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "trace.jsonl")
+	sink, err := NewFileSink(path, 200, 2)
+	if err != nil {
+		t.Fatalf("failed to create file sink: %v", err)
+	}
+	defer sink.Close()
+
+	for i := 0; i < 10; i++ {
+		if err := sink.Emit(TraceRecord{LogType: tag.ControllerOperationKey, Payload: `{"op_type":"GET"}`}); err != nil {
+			t.Fatalf("failed to emit record: %v", err)
+		}
+	}
+
+	for _, name := range []string{path, path + ".1", path + ".2"} {
+		info, err := os.Stat(name)
+		if err != nil {
+			t.Fatalf("expected %s to exist: %v", name, err)
+		}
+		if info.Size() > 200 {
+			t.Errorf("%s exceeds max size: %d", name, info.Size())
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Errorf("expected at most 2 backups, found %s.3", path)
+	}
+}
+
+func TestRecordsAreSequenced(t *testing.T) {
+	sink := NewMemorySink()
+	for i := 0; i < 3; i++ {
+		Emit(sink, log, tag.ControllerOperationKey, `{"op_type":"GET"}`)
+	}
+	records := sink.Records()
+	for i, r := range records {
+		stamp, err := trace.StampOf(r.Payload)
+		if err != nil {
+			t.Fatalf("failed to decode stamp: %v", err)
+		}
+		if stamp.ProcessID != processID {
+			t.Errorf("record %d has process ID %q, want %q", i, stamp.ProcessID, processID)
+		}
+		if first, _ := trace.StampOf(records[0].Payload); stamp.Seq != first.Seq+uint64(i) {
+			t.Errorf("record %d has sequence number %d, want %d", i, stamp.Seq, first.Seq+uint64(i))
+		}
+	}
+}
+
+func TestFileSinkRecoversFromFailedRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "trace.jsonl")
+	sink, err := NewFileSink(path, 100, 1)
+	if err != nil {
+		t.Fatalf("failed to create file sink: %v", err)
+	}
+	defer sink.Close()
+
+	record := TraceRecord{LogType: tag.ControllerOperationKey, Payload: `{"op_type":"GET"}`}
+	if err := sink.Emit(record); err != nil {
+		t.Fatalf("failed to emit record: %v", err)
+	}
+	// a non-empty directory in place of the first backup makes the rename fail
+	if err := os.MkdirAll(filepath.Join(path+".1", "blocked"), 0755); err != nil {
+		t.Fatalf("failed to create directory: %v", err)
+	}
+	if err := sink.Emit(record); err == nil {
+		t.Fatalf("expected rotation to fail")
+	}
+	if err := os.RemoveAll(path + ".1"); err != nil {
+		t.Fatalf("failed to remove directory: %v", err)
+	}
+	if err := sink.Emit(record); err != nil {
+		t.Fatalf("expected the sink to recover after a failed rotation: %v", err)
+	}
+	if _, err := os.Stat(path + ".1"); err != nil {
+		t.Errorf("expected the retried rotation to produce a backup: %v", err)
+	}
+}
+
+func TestMemorySink(t *testing.T) {
+	sink := NewMemorySink()
+	Emit(sink, log, tag.ControllerOperationKey, `{"op_type":"GET","kind":"ConfigMap"}`)
+	Emit(sink, log, tag.ObjectVersionKey, `{"kind":"ConfigMap"}`)
+
+	records := sink.Records()
+	if len(records) != 2 || records[0].LogType != tag.ControllerOperationKey || records[1].LogType != tag.ObjectVersionKey {
+		t.Fatalf("unexpected records: %+v", records)
+	}
+	records[0].LogType = "modified"
+	if sink.Records()[0].LogType != tag.ControllerOperationKey {
+		t.Errorf("expected Records to return a copy")
+	}
+
+	events, err := sink.Events()
+	if err != nil {
+		t.Fatalf("failed to decode events: %v", err)
+	}
+	if len(events) != 1 || events[0].OpType != "GET" || events[0].Kind != "ConfigMap" {
+		t.Errorf("expected only the controller operation to be decoded, got %+v", events)
+	}
+}
+
+func TestChannelSink(t *testing.T) {
+	ch := make(chan TraceRecord, 1)
+	sink := NewChannelSink(ch)
+	if err := sink.Emit(TraceRecord{LogType: tag.ControllerOperationKey, Payload: "payload"}); err != nil {
+		t.Fatalf("failed to emit record: %v", err)
+	}
+	select {
+	case r := <-ch:
+		if r.LogType != tag.ControllerOperationKey || r.Payload != "payload" {
+			t.Errorf("unexpected record: %+v", r)
+		}
+	default:
+		t.Fatalf("expected the record to be forwarded to the channel")
+	}
+}
+
+func TestLogrSink(t *testing.T) {
+	var lines []string
+	logger := funcr.New(func(prefix, args string) {
+		lines = append(lines, args)
+	}, funcr.Options{})
+	if err := NewLogrSink(logger).Emit(TraceRecord{LogType: tag.ControllerOperationKey, Payload: "payload"}); err != nil {
+		t.Fatalf("failed to emit record: %v", err)
+	}
+	if len(lines) != 1 {
+		t.Fatalf("expected 1 log line, got %d", len(lines))
+	}
+	if !strings.Contains(lines[0], `"msg"="payload"`) || !strings.Contains(lines[0], `"LogType"="`+tag.ControllerOperationKey+`"`) {
+		t.Errorf("unexpected log line: %s", lines[0])
+	}
+}
+
+func TestSlowSinkDoesNotBlockOtherSinks(t *testing.T) {
+	blocked := make(chan TraceRecord)
+	go Emit(NewChannelSink(blocked), log, tag.ControllerOperationKey, `{"op_type":"GET"}`)
+
+	done := make(chan struct{})
+	go func() {
+		Emit(NewMemorySink(), log, tag.ControllerOperationKey, `{"op_type":"GET"}`)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("emitting to a sink was blocked by another sink")
+	}
+	<-blocked
+}
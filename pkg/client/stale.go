@@ -0,0 +1,130 @@
+package client
+
+import (
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/tgoodwin/sleeve/pkg/event"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const defaultStaleHistorySize = 10
+
+// StalePolicy decides when reads of a kind are served an older version of an object
+// than the one the apiserver returned. Only versions that the client has itself observed
+// can be served, so a stale read never fabricates state.
+type StalePolicy struct {
+	// Seed for the random source that decides which reads are stale.
+	Seed int64
+
+	// Probability that a read is served a stale version.
+	Probability float64
+
+	// MaxStaleness bounds how many observed versions behind the latest a stale read can be.
+	// Zero means any retained version may be served.
+	MaxStaleness int
+
+	// FrozenFrom and FrozenUntil pin a frozen window: reads within the window are always served
+	// the newest version that was observed before the window started, regardless of Probability.
+	FrozenFrom  time.Time
+	FrozenUntil time.Time
+
+	// HistorySize bounds the number of versions retained per object. Defaults to 10.
+	HistorySize int
+
+	// Now is the time source against which the frozen window is evaluated. Defaults to time.Now.
+	Now func() time.Time
+}
+
+// staleReads holds the per-kind state needed to apply a StalePolicy.
+type staleReads struct {
+	policy  StalePolicy
+	rng     *rand.Rand
+	history *versionHistory
+	mu      sync.Mutex
+}
+
+func newStaleReads(policy StalePolicy) *staleReads {
+	if policy.HistorySize <= 0 {
+		policy.HistorySize = defaultStaleHistorySize
+	}
+	if policy.Now == nil {
+		policy.Now = time.Now
+	}
+	return &staleReads{
+		policy:  policy,
+		rng:     rand.New(rand.NewSource(policy.Seed)),
+		history: newVersionHistory(policy.HistorySize),
+	}
+}
+
+func StaleReads(gk schema.GroupKind, policy StalePolicy) Option {
+	return func(o *Config) {
+		if o.staleReadsByKind == nil {
+			o.staleReadsByKind = make(map[schema.GroupKind]*staleReads)
+		}
+		o.staleReadsByKind[gk] = newStaleReads(policy)
+	}
+}
+
+// observe records the version of obj that the apiserver returned and returns a version
+// to serve in its place, or nil if the read should not be stale.
+func (s *staleReads) observe(obj client.Object, now time.Time) (client.Object, int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	versions := s.history.record(obj, now)
+	latest := len(versions) - 1
+	oldest := 0
+	if s.policy.MaxStaleness > 0 && latest-s.policy.MaxStaleness > oldest {
+		oldest = latest - s.policy.MaxStaleness
+	}
+
+	if !s.policy.FrozenFrom.IsZero() && !now.Before(s.policy.FrozenFrom) && now.Before(s.policy.FrozenUntil) {
+		for i := latest; i >= oldest; i-- {
+			if versions[i].observedAt.Before(s.policy.FrozenFrom) {
+				if i == latest {
+					return nil, 0
+				}
+				return versions[i].obj, latest - i
+			}
+		}
+		return nil, 0
+	}
+
+	if latest == oldest || s.rng.Float64() >= s.policy.Probability {
+		return nil, 0
+	}
+	i := oldest + s.rng.Intn(latest-oldest)
+	return versions[i].obj, latest - i
+}
+
+// maybeServeStale replaces obj with an older observed version if a stale read policy applies to its kind.
+func (c *Client) maybeServeStale(rc *ReconcileContext, obj client.Object, op OperationType) {
+	gvk := c.groupVersionKindFor(obj)
+	stale, ok := c.config.staleReadsByKind[gvk.GroupKind()]
+	if !ok {
+		return
+	}
+	served, behind := stale.observe(obj, stale.policy.Now())
+	if served == nil {
+		return
+	}
+	latestVersion := obj.GetResourceVersion()
+	setObject(obj, served)
+	c.logFault(rc, &event.Fault{
+		FaultType:     event.FaultStaleRead,
+		OpType:        string(op),
+		Kind:          gvk.Kind,
+		APIVersion:    gvk.GroupVersion().String(),
+		ObjectID:      string(obj.GetUID()),
+		Namespace:     obj.GetNamespace(),
+		Name:          obj.GetName(),
+		Version:       obj.GetResourceVersion(),
+		LatestVersion: latestVersion,
+		Detail:        fmt.Sprintf("%d versions behind", behind),
+	})
+}
@@ -0,0 +1,97 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/tgoodwin/sleeve/pkg/event"
+	"github.com/tgoodwin/sleeve/pkg/tag"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func faults(t *testing.T, sink *MemorySink) []event.Fault {
+	t.Helper()
+	out := make([]event.Fault, 0)
+	for _, r := range sink.Records() {
+		if r.LogType != tag.FaultKey {
+			continue
+		}
+		var f event.Fault
+		if err := json.Unmarshal([]byte(r.Payload), &f); err != nil {
+			t.Fatalf("failed to decode fault: %v", err)
+		}
+		out = append(out, f)
+	}
+	return out
+}
+
+func TestStaleReads(t *testing.T) {
+	key := client.ObjectKey{Namespace: "default", Name: "cm"}
+	cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: key.Name, Namespace: key.Namespace}, Data: map[string]string{"scale": "1"}}
+
+	tests := []struct {
+		name   string
+		policy func(now func() time.Time) StalePolicy
+	}{
+		{
+			name: "probability",
+			policy: func(now func() time.Time) StalePolicy {
+				return StalePolicy{Seed: 1, Probability: 1, Now: now}
+			},
+		},
+		{
+			name: "frozen window",
+			policy: func(now func() time.Time) StalePolicy {
+				return StalePolicy{FrozenFrom: now().Add(time.Minute), FrozenUntil: now().Add(time.Hour), Now: now}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			underlying := fake.NewClientBuilder().WithObjects(cm.DeepCopy()).Build()
+			sink := NewMemorySink()
+			now := time.Now()
+			clock := func() time.Time { return now }
+			c := Wrap(underlying).WithName("test-controller").WithOptions(WithSink(sink), StaleReads(schema.GroupKind{Kind: "ConfigMap"}, tt.policy(clock)))
+			ctx := WithReconcileID(context.Background(), "reconcile-1")
+
+			first := &corev1.ConfigMap{}
+			if err := c.Get(ctx, key, first); err != nil {
+				t.Fatalf("get failed: %v", err)
+			}
+
+			latest := &corev1.ConfigMap{}
+			if err := underlying.Get(ctx, key, latest); err != nil {
+				t.Fatalf("get failed: %v", err)
+			}
+			latest.Data["scale"] = "2"
+			if err := underlying.Update(ctx, latest); err != nil {
+				t.Fatalf("update failed: %v", err)
+			}
+			now = now.Add(2 * time.Minute)
+
+			served := &corev1.ConfigMap{}
+			if err := c.Get(ctx, key, served); err != nil {
+				t.Fatalf("get failed: %v", err)
+			}
+			if served.Data["scale"] != "1" || served.GetResourceVersion() != first.GetResourceVersion() {
+				t.Errorf("expected stale version %s, got %s with scale=%s", first.GetResourceVersion(), served.GetResourceVersion(), served.Data["scale"])
+			}
+
+			fs := faults(t, sink)
+			if len(fs) != 1 {
+				t.Fatalf("expected 1 fault record, got %d", len(fs))
+			}
+			if fs[0].FaultType != event.FaultStaleRead || fs[0].Version != first.GetResourceVersion() || fs[0].LatestVersion != latest.GetResourceVersion() ||
+				fs[0].Namespace != "default" || fs[0].Name != latest.GetName() {
+				t.Errorf("unexpected fault record: %+v", fs[0])
+			}
+		})
+	}
+}
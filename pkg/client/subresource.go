@@ -5,62 +5,172 @@ import (
 	"encoding/json"
 	"fmt"
 
+	"github.com/tgoodwin/sleeve/pkg/event"
 	"github.com/tgoodwin/sleeve/pkg/tag"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	kclient "sigs.k8s.io/controller-runtime/pkg/client"
 )
 
 var _ client.StatusClient = &Client{}
+var _ client.SubResourceClientConstructor = &Client{}
+var _ client.SubResourceClient = &SubResourceClient{}
 
 type SubResourceClient struct {
-	// reader kclient.SubResourceReader
 	client *Client
+	reader kclient.SubResourceReader
 	writer kclient.SubResourceWriter
+
+	// name of the subresource, e.g. "status"
+	subResource string
 }
 
 func (c *Client) Status() kclient.SubResourceWriter {
 	statusClient := c.Client.Status()
-	return &SubResourceClient{writer: statusClient, client: c}
+	return &SubResourceClient{writer: statusClient, client: c, subResource: "status"}
 }
 
-func (s *SubResourceClient) logOperation(obj kclient.Object, action OperationType) {
-	s.client.logOperation(obj, action)
+// SubResource returns a client for the named subresource (e.g. "scale", "eviction", "binding")
+// whose operations are traced like any other controller operation.
+func (c *Client) SubResource(subResource string) kclient.SubResourceClient {
+	inner := c.Client.SubResource(subResource)
+	return &SubResourceClient{reader: inner, writer: inner, client: c, subResource: subResource}
 }
 
-func (s *SubResourceClient) Update(ctx context.Context, obj kclient.Object, opts ...kclient.SubResourceUpdateOption) error {
-	s.client.setReconcileID(ctx)
+func (s *SubResourceClient) operation(rc *ReconcileContext, obj kclient.Object, action OperationType) *event.Event {
+	e := s.client.operation(rc, obj, action)
+	e.SubResource = s.subResource
+	return e
+}
+
+// Get reads the subresource of obj into sub. The read is traced like a Get of obj, so the event is recorded
+// against obj's kind, but with the identity and version that the apiserver returned in sub.
+func (s *SubResourceClient) Get(ctx context.Context, obj kclient.Object, sub kclient.Object, opts ...kclient.SubResourceGetOption) error {
+	if s.reader == nil {
+		return fmt.Errorf("subresource %s does not support get", s.subResource)
+	}
+	if err := s.injectError(ctx, obj, GET); err != nil {
+		return err
+	}
+	if err := s.reader.Get(ctx, obj, sub, opts...); err != nil {
+		return err
+	}
+	e := s.client.prepareOperation(ctx, obj, GET)
+	e.SubResource = s.subResource
+	if uid := sub.GetUID(); uid != "" {
+		e.ObjectID = string(uid)
+	}
+	if rv := sub.GetResourceVersion(); rv != "" {
+		e.Version = rv
+	}
+	s.client.logEvent(e)
+	return nil
+}
+
+func (s *SubResourceClient) injectError(ctx context.Context, obj kclient.Object, action OperationType) error {
+	return s.client.injectError(ctx, s.client.groupVersionKindFor(obj), kclient.ObjectKeyFromObject(obj), action)
+}
+
+// prepareWrite labels a write to the subresource and returns its event along with the sleeve labels
+// that need to be persisted on the object once the write has gone through.
+func (s *SubResourceClient) prepareWrite(ctx context.Context, obj kclient.Object, action OperationType) (*event.Event, map[string]string) {
+	rc := s.client.reconcileContext(ctx)
 	tag.LabelChange(obj)
-	s.logOperation(obj, UPDATE)
-	s.client.propagateLabels(obj)
-	// fmt.Printf("extracted conditions: %v", conditions)
-	// persist the labels to the object before updating status
-
-	// update status
-	// TODO this does not work. "the object has been modified; please apply your changes to the latest version and try again"
-	res := s.writer.Update(ctx, obj, opts...)
-	s.client.Update(ctx, obj)
-	return res
+	e := s.operation(rc, obj, action)
+	s.client.propagateLabels(rc, obj, e.Clock)
+	return e, tag.GetSleeveLabels(obj)
+}
+
+// persistLabels stamps the given sleeve labels onto the version of obj that the subresource write produced.
+// The apiserver ignores metadata changes made through a subresource, so the labels set in prepareWrite
+// are dropped by the subresource write itself. The patch is conditioned on obj's resourceVersion,
+// so the labels never land on a version that another writer produced in the meantime.
+// obj is only advanced to the labeled version if the patch succeeds.
+func (s *SubResourceClient) persistLabels(ctx context.Context, obj kclient.Object, labels map[string]string) error {
+	data, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"labels":          labels,
+			"resourceVersion": obj.GetResourceVersion(),
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("marshaling sleeve labels: %w", err)
+	}
+	// use the wrapped client so that the label patch does not show up as a controller operation
+	labeled := obj.DeepCopyObject().(kclient.Object)
+	if err := s.client.Client.Patch(ctx, labeled, kclient.RawPatch(types.MergePatchType, data)); err != nil {
+		return err
+	}
+	// the precondition guarantees that the patch changed nothing but the metadata
+	obj.SetLabels(labeled.GetLabels())
+	obj.SetResourceVersion(labeled.GetResourceVersion())
+	obj.SetManagedFields(labeled.GetManagedFields())
+	return nil
+}
+
+// finishWrite completes a subresource write that went through. The write itself has succeeded by now,
+// so failing to persist its labels only costs the trace its link to this version, and is not returned to the caller.
+// The event records the version that the write produced along with the outcome of the label patch.
+func (s *SubResourceClient) finishWrite(ctx context.Context, e *event.Event, obj kclient.Object, labels map[string]string) {
+	setResult(e, obj, nil)
+	if err := s.persistLabels(ctx, obj, labels); err != nil {
+		s.client.logger.V(1).Error(err, "failed to persist sleeve labels after subresource write",
+			"SubResource", s.subResource, "Kind", e.Kind, "Namespace", e.Namespace, "Name", e.Name)
+		e.LabelError = err.Error()
+	} else {
+		e.LabeledVersion = obj.GetResourceVersion()
+	}
+	s.client.observe(ctx, obj)
+	s.client.logEvent(e)
+}
+
+func (s *SubResourceClient) Update(ctx context.Context, obj kclient.Object, opts ...kclient.SubResourceUpdateOption) error {
+	e, labels := s.prepareWrite(ctx, obj, UPDATE)
+	s.client.recordDelta(ctx, e, obj)
+	err := s.injectError(ctx, obj, UPDATE)
+	if err == nil {
+		err = s.writer.Update(ctx, obj, opts...)
+	}
+	if err != nil {
+		s.client.logResult(e, obj, err)
+		return err
+	}
+	s.finishWrite(ctx, e, obj, labels)
+	return nil
 }
 
 func (s *SubResourceClient) Patch(ctx context.Context, obj kclient.Object, patch kclient.Patch, opts ...kclient.SubResourcePatchOption) error {
-	s.client.setReconcileID(ctx)
-	tag.LabelChange(obj)
-	s.logOperation(obj, PATCH)
-	// persist the labels to the object before updating status
-	s.client.Update(ctx, obj)
-	return s.writer.Patch(ctx, obj, patch, opts...)
+	e, labels := s.prepareWrite(ctx, obj, PATCH)
+	s.client.recordPatch(e, obj, patch, &(&kclient.SubResourcePatchOptions{}).ApplyOptions(opts).PatchOptions)
+	if patch.Type() != types.ApplyPatchType {
+		s.client.recordDelta(ctx, e, obj)
+	}
+	err := s.injectError(ctx, obj, PATCH)
+	if err == nil {
+		err = s.writer.Patch(ctx, obj, patch, opts...)
+	}
+	if err != nil {
+		s.client.logResult(e, obj, err)
+		return err
+	}
+	s.finishWrite(ctx, e, obj, labels)
+	return nil
 }
 
+// Create logs the creation of a subresource (e.g. an eviction or binding) on obj.
+// Creating a subresource does not produce a version of obj that the controller authored,
+// so no change is labeled on obj.
 func (s *SubResourceClient) Create(ctx context.Context, obj kclient.Object, sub kclient.Object, opts ...kclient.SubResourceCreateOption) error {
-	s.client.setReconcileID(ctx)
-	tag.LabelChange(obj)
-	s.logOperation(obj, CREATE)
-	s.client.propagateLabels(obj)
-	s.client.Update(ctx, obj)
-
-	return s.writer.Create(ctx, obj, sub, opts...)
+	rc := s.client.reconcileContext(ctx)
+	e := s.operation(rc, obj, CREATE)
+	err := s.injectError(ctx, obj, CREATE)
+	if err == nil {
+		err = s.writer.Create(ctx, obj, sub, opts...)
+	}
+	s.client.logResult(e, obj, err)
+	return err
 }
 
 // Extracts status.conditions from an arbitrary client.Object
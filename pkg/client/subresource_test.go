@@ -0,0 +1,290 @@
+package client
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/tgoodwin/sleeve/pkg/event"
+	"github.com/tgoodwin/sleeve/pkg/tag"
+	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv1 "k8s.io/api/autoscaling/v1"
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+)
+
+func newStatusTestClient(t *testing.T) (*Client, client.Client, *MemorySink) {
+	t.Helper()
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "pod",
+			Namespace: "default",
+			Labels:    map[string]string{tag.TraceyWebhookLabel: "root", "app": "test"},
+		},
+	}
+	underlying := fake.NewClientBuilder().WithObjects(pod).WithStatusSubresource(pod).Build()
+	sink := NewMemorySink()
+	c := Wrap(underlying).WithName("test-controller").WithOptions(WithSink(sink))
+	return c, underlying, sink
+}
+
+func lastEvent(t *testing.T, sink *MemorySink) event.Event {
+	t.Helper()
+	events, err := sink.Events()
+	if err != nil {
+		t.Fatalf("failed to decode events: %v", err)
+	}
+	if len(events) == 0 {
+		t.Fatalf("no events emitted")
+	}
+	return events[len(events)-1]
+}
+
+func assertStatusPersisted(t *testing.T, underlying client.Client, changeID string) {
+	t.Helper()
+	stored := &corev1.Pod{}
+	if err := underlying.Get(context.Background(), client.ObjectKey{Namespace: "default", Name: "pod"}, stored); err != nil {
+		t.Fatalf("failed to get stored pod: %v", err)
+	}
+	if stored.Status.Phase != corev1.PodRunning {
+		t.Errorf("status not persisted, phase is %q", stored.Status.Phase)
+	}
+	labels := stored.GetLabels()
+	if labels[tag.ChangeID] != changeID {
+		t.Errorf("change-id label is %q, want %q", labels[tag.ChangeID], changeID)
+	}
+	if labels[tag.TraceyCreatorID] != "test-controller" {
+		t.Errorf("creator-id label is %q", labels[tag.TraceyCreatorID])
+	}
+	if labels["app"] != "test" {
+		t.Errorf("non-sleeve labels were clobbered: %v", labels)
+	}
+}
+
+func TestStatusUpdate(t *testing.T) {
+	c, underlying, sink := newStatusTestClient(t)
+	ctx := WithReconcileID(context.Background(), "reconcile-1")
+
+	pod := &corev1.Pod{}
+	if err := c.Get(ctx, client.ObjectKey{Namespace: "default", Name: "pod"}, pod); err != nil {
+		t.Fatalf("get failed: %v", err)
+	}
+	pod.Status.Phase = corev1.PodRunning
+	if err := c.Status().Update(ctx, pod); err != nil {
+		t.Fatalf("status update failed: %v", err)
+	}
+
+	e := lastEvent(t, sink)
+	if e.OpType != string(UPDATE) || e.SubResource != "status" {
+		t.Errorf("expected a status UPDATE event, got op=%s subresource=%q", e.OpType, e.SubResource)
+	}
+	assertStatusPersisted(t, underlying, string(e.ChangeID()))
+	if e.ResultVersion == "" || e.ResultVersion == e.LabeledVersion || e.LabeledVersion != pod.GetResourceVersion() {
+		t.Errorf("expected the event to record the status write's version and the later labeled version %s, got result=%q labeled=%q",
+			pod.GetResourceVersion(), e.ResultVersion, e.LabeledVersion)
+	}
+
+	// the object handed back to the controller is current, so a subsequent spec write must not conflict
+	pod.Spec.ActiveDeadlineSeconds = new(int64)
+	if err := c.Update(ctx, pod); err != nil {
+		t.Errorf("update after status update failed: %v", err)
+	}
+	if e := lastEvent(t, sink); e.SubResource != "" {
+		t.Errorf("spec write recorded with subresource %q", e.SubResource)
+	}
+}
+
+func TestStatusPatch(t *testing.T) {
+	c, underlying, sink := newStatusTestClient(t)
+	ctx := WithReconcileID(context.Background(), "reconcile-1")
+
+	pod := &corev1.Pod{}
+	if err := c.Get(ctx, client.ObjectKey{Namespace: "default", Name: "pod"}, pod); err != nil {
+		t.Fatalf("get failed: %v", err)
+	}
+	base := pod.DeepCopy()
+	pod.Status.Phase = corev1.PodRunning
+	if err := c.Status().Patch(ctx, pod, client.MergeFrom(base)); err != nil {
+		t.Fatalf("status patch failed: %v", err)
+	}
+
+	e := lastEvent(t, sink)
+	if e.OpType != string(PATCH) || e.SubResource != "status" {
+		t.Errorf("expected a status PATCH event, got op=%s subresource=%q", e.OpType, e.SubResource)
+	}
+	if e.PatchType != string(types.MergePatchType) || !strings.Contains(e.PatchData, `"phase":"Running"`) {
+		t.Errorf("expected the status patch to be recorded, got type=%s data=%s", e.PatchType, e.PatchData)
+	}
+	assertStatusPersisted(t, underlying, string(e.ChangeID()))
+}
+
+func TestStatusCreate(t *testing.T) {
+	c, _, sink := newStatusTestClient(t)
+	ctx := WithReconcileID(context.Background(), "reconcile-1")
+
+	pod := &corev1.Pod{}
+	if err := c.Get(ctx, client.ObjectKey{Namespace: "default", Name: "pod"}, pod); err != nil {
+		t.Fatalf("get failed: %v", err)
+	}
+	// the fake client does not support creating the status subresource,
+	// but the attempt must still be traced
+	if err := c.Status().Create(ctx, pod, &policyv1.Eviction{}); err == nil {
+		t.Errorf("expected status create to fail against the fake client")
+	}
+
+	e := lastEvent(t, sink)
+	if e.OpType != string(CREATE) || e.SubResource != "status" {
+		t.Errorf("expected a status CREATE event, got op=%s subresource=%q", e.OpType, e.SubResource)
+	}
+}
+
+func TestSubResourceEviction(t *testing.T) {
+	c, underlying, sink := newStatusTestClient(t)
+	ctx := WithReconcileID(context.Background(), "reconcile-1")
+
+	pod := &corev1.Pod{}
+	if err := c.Get(ctx, client.ObjectKey{Namespace: "default", Name: "pod"}, pod); err != nil {
+		t.Fatalf("get failed: %v", err)
+	}
+	if err := c.SubResource("eviction").Create(ctx, pod, &policyv1.Eviction{}); err != nil {
+		t.Fatalf("eviction failed: %v", err)
+	}
+	if err := underlying.Get(ctx, client.ObjectKey{Namespace: "default", Name: "pod"}, &corev1.Pod{}); err == nil {
+		t.Errorf("expected pod to be evicted")
+	}
+
+	e := lastEvent(t, sink)
+	if e.OpType != string(CREATE) || e.SubResource != "eviction" || e.Kind != "Pod" {
+		t.Errorf("expected an eviction CREATE event on a Pod, got op=%s subresource=%q kind=%s", e.OpType, e.SubResource, e.Kind)
+	}
+	if e.ObjectID != string(pod.GetUID()) {
+		t.Errorf("eviction event has object ID %q, want %q", e.ObjectID, pod.GetUID())
+	}
+}
+
+func TestStatusUpdateRacingWriter(t *testing.T) {
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod", Namespace: "default"}}
+	underlying := fake.NewClientBuilder().
+		WithObjects(pod).
+		WithStatusSubresource(pod).
+		WithInterceptorFuncs(interceptor.Funcs{
+			SubResourceUpdate: func(ctx context.Context, c client.Client, subResource string, obj client.Object, opts ...client.SubResourceUpdateOption) error {
+				if err := c.SubResource(subResource).Update(ctx, obj, opts...); err != nil {
+					return err
+				}
+				// another writer updates the object between the status write and the label patch
+				other := &corev1.Pod{}
+				if err := c.Get(ctx, client.ObjectKeyFromObject(obj), other); err != nil {
+					return err
+				}
+				other.Labels = map[string]string{"other": "writer"}
+				return c.Update(ctx, other)
+			},
+		}).
+		Build()
+	sink := NewMemorySink()
+	c := Wrap(underlying).WithName("test-controller").WithOptions(WithSink(sink))
+	ctx := WithReconcileID(context.Background(), "reconcile-1")
+
+	obj := &corev1.Pod{}
+	if err := c.Get(ctx, client.ObjectKeyFromObject(pod), obj); err != nil {
+		t.Fatalf("get failed: %v", err)
+	}
+	obj.Status.Phase = corev1.PodRunning
+	if err := c.Status().Update(ctx, obj); err != nil {
+		t.Fatalf("a status update that went through must not fail: %v", err)
+	}
+	e := lastEvent(t, sink)
+	if e.Failed() {
+		t.Errorf("expected the status update to be traced as a success, got %+v", e)
+	}
+	if e.LabelError == "" || e.LabeledVersion != "" {
+		t.Errorf("expected the event to report the failed label patch, got label_error=%q labeled_version=%q", e.LabelError, e.LabeledVersion)
+	}
+	if obj.GetResourceVersion() != e.ResultVersion {
+		t.Errorf("expected the object to be left at the status write's version %s, got %s", e.ResultVersion, obj.GetResourceVersion())
+	}
+
+	stored := &corev1.Pod{}
+	if err := underlying.Get(ctx, client.ObjectKeyFromObject(pod), stored); err != nil {
+		t.Fatalf("failed to get stored pod: %v", err)
+	}
+	if _, ok := stored.GetLabels()[tag.ChangeID]; ok || stored.GetLabels()["other"] != "writer" {
+		t.Errorf("expected the other writer's version to be left unlabeled, got labels %v", stored.GetLabels())
+	}
+}
+
+// newScaleTestClient returns a fake client that serves the scale subresource of deployments,
+// which the fake client does not support on its own.
+func newScaleTestClient(objs ...client.Object) client.Client {
+	return fake.NewClientBuilder().
+		WithObjects(objs...).
+		WithInterceptorFuncs(interceptor.Funcs{
+			SubResourceGet: func(ctx context.Context, c client.Client, subResource string, obj client.Object, sub client.Object, opts ...client.SubResourceGetOption) error {
+				dep := &appsv1.Deployment{}
+				if err := c.Get(ctx, client.ObjectKeyFromObject(obj), dep); err != nil {
+					return err
+				}
+				scale := sub.(*autoscalingv1.Scale)
+				scale.ObjectMeta = metav1.ObjectMeta{Name: dep.Name, Namespace: dep.Namespace, UID: dep.UID, ResourceVersion: dep.ResourceVersion}
+				if dep.Spec.Replicas != nil {
+					scale.Spec.Replicas = *dep.Spec.Replicas
+				}
+				return nil
+			},
+		}).
+		Build()
+}
+
+func TestSubResourceGet(t *testing.T) {
+	dep := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "dep",
+			Namespace: "default",
+			Labels:    map[string]string{tag.TraceyWebhookLabel: "root"},
+		},
+	}
+	sink := NewMemorySink()
+	c := Wrap(newScaleTestClient(dep)).
+		WithName("test-controller").
+		WithOptions(WithSink(sink))
+	ctx := WithReconcileID(context.Background(), "reconcile-1")
+
+	scale := &autoscalingv1.Scale{}
+	if err := c.SubResource("scale").Get(ctx, dep, scale); err != nil {
+		t.Fatalf("scale get failed: %v", err)
+	}
+	e := lastEvent(t, sink)
+	if e.OpType != string(GET) || e.SubResource != "scale" || e.Kind != "Deployment" || e.APIVersion != "apps/v1" {
+		t.Errorf("expected a scale GET event on an apps/v1 Deployment, got op=%s subresource=%q kind=%s apiVersion=%s",
+			e.OpType, e.SubResource, e.Kind, e.APIVersion)
+	}
+	if e.Version != scale.GetResourceVersion() {
+		t.Errorf("expected the event to record the version the apiserver returned, got %q want %q", e.Version, scale.GetResourceVersion())
+	}
+	if e.RootEventID != "root" {
+		t.Errorf("expected the read to set the reconcile's root, got %q", e.RootEventID)
+	}
+}
+
+func TestSubResourceGetInjectsErrors(t *testing.T) {
+	dep := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "dep", Namespace: "default"}}
+	c := Wrap(newScaleTestClient(dep)).
+		WithName("test-controller").
+		WithOptions(
+			WithSink(NewMemorySink()),
+			InjectErrors(schema.GroupKind{Group: "apps", Kind: "Deployment"}, ErrorPolicy{Ops: []OperationType{GET}, Errors: []APIError{ErrTimeout}, Probability: 1}),
+		)
+	ctx := WithReconcileID(context.Background(), "reconcile-1")
+
+	if err := c.SubResource("scale").Get(ctx, dep, &autoscalingv1.Scale{}); !apierrors.IsTimeout(err) {
+		t.Errorf("expected the injected timeout, got %v", err)
+	}
+}
@@ -0,0 +1,85 @@
+package client
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/tgoodwin/sleeve/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const visibilityHistorySize = 10
+
+// visibilityLag holds the per-kind state needed to model informer lag.
+type visibilityLag struct {
+	delay   time.Duration
+	history *versionHistory
+	mu      sync.Mutex
+}
+
+func newVisibilityLag(delay time.Duration) *visibilityLag {
+	return &visibilityLag{delay: delay, history: newVersionHistory(visibilityHistorySize)}
+}
+
+// observe records the version of obj that the apiserver returned and returns the newest version
+// that was written at least delay ago. It reports false if no version of the object is visible yet.
+func (v *visibilityLag) observe(obj client.Object, now time.Time) (client.Object, bool) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	versions := v.history.record(obj, now)
+	cutoff := now.Add(-v.delay)
+	for i := len(versions) - 1; i >= 0; i-- {
+		if !versions[i].writtenAt.After(cutoff) {
+			return versions[i].obj, true
+		}
+	}
+	if now.Sub(obj.GetCreationTimestamp().Time) < v.delay {
+		return nil, false
+	}
+	// the object is not new, but we never observed a version old enough to serve
+	return obj, true
+}
+
+// applyVisibility returns the version of obj that is visible to the controller under the visibility delay
+// configured for its kind, or false if the object is hidden. Hidden objects and lagged versions are recorded as faults.
+func (c *Client) applyVisibility(rc *ReconcileContext, obj client.Object, op OperationType) (client.Object, bool) {
+	gvk := c.groupVersionKindFor(obj)
+	lag, ok := c.config.visibilityByKind[gvk.GroupKind()]
+	if !ok {
+		if lag, ok = c.config.visibilityByKindName[gvk.Kind]; !ok {
+			return obj, true
+		}
+	}
+	served, visible := lag.observe(obj, time.Now())
+	if !visible {
+		c.logFault(rc, &event.Fault{
+			FaultType:     event.FaultVisibilityDelay,
+			OpType:        string(op),
+			Kind:          gvk.Kind,
+			APIVersion:    gvk.GroupVersion().String(),
+			ObjectID:      string(obj.GetUID()),
+			Namespace:     obj.GetNamespace(),
+			Name:          obj.GetName(),
+			LatestVersion: obj.GetResourceVersion(),
+			Detail:        "hidden",
+		})
+		return nil, false
+	}
+	if served.GetResourceVersion() != obj.GetResourceVersion() {
+		c.logFault(rc, &event.Fault{
+			FaultType:     event.FaultVisibilityDelay,
+			OpType:        string(op),
+			Kind:          gvk.Kind,
+			APIVersion:    gvk.GroupVersion().String(),
+			ObjectID:      string(obj.GetUID()),
+			Namespace:     obj.GetNamespace(),
+			Name:          obj.GetName(),
+			Version:       served.GetResourceVersion(),
+			LatestVersion: obj.GetResourceVersion(),
+			Detail:        fmt.Sprintf("served previous version within %s lag", lag.delay),
+		})
+	}
+	return served, true
+}
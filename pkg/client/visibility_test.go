@@ -0,0 +1,100 @@
+package client
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/tgoodwin/sleeve/pkg/event"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestVisibilityDelay(t *testing.T) {
+	// creation timestamps only have second granularity
+	const delay = 1500 * time.Millisecond
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "cm", Namespace: "default", CreationTimestamp: metav1.Now()},
+		Data:       map[string]string{"v": "1"},
+	}
+	underlying := fake.NewClientBuilder().WithObjects(cm).Build()
+	sink := NewMemorySink()
+	c := Wrap(underlying).WithName("test-controller").WithOptions(WithSink(sink), VisibilityDelayFor(schema.GroupKind{Kind: "ConfigMap"}, delay))
+	ctx := WithReconcileID(context.Background(), "reconcile-1")
+	key := client.ObjectKeyFromObject(cm)
+
+	read := func() (string, int) {
+		t.Helper()
+		list := &corev1.ConfigMapList{}
+		if err := c.List(ctx, list); err != nil {
+			t.Fatalf("list failed: %v", err)
+		}
+		obj := &corev1.ConfigMap{}
+		err := c.Get(ctx, key, obj)
+		if apierrors.IsNotFound(err) {
+			return "", len(list.Items)
+		}
+		if err != nil {
+			t.Fatalf("get failed: %v", err)
+		}
+		if len(list.Items) != 1 || list.Items[0].Data["v"] != obj.Data["v"] {
+			t.Fatalf("list and get disagree: list=%v get=%v", list.Items, obj.Data)
+		}
+		return obj.Data["v"], len(list.Items)
+	}
+
+	if v, n := read(); v != "" || n != 0 {
+		t.Errorf("expected newly created object to be hidden, got v=%q and %d list items", v, n)
+	}
+	time.Sleep(delay)
+	if v, _ := read(); v != "1" {
+		t.Errorf("expected first version to be visible, got %q", v)
+	}
+
+	latest := &corev1.ConfigMap{}
+	if err := underlying.Get(ctx, key, latest); err != nil {
+		t.Fatalf("get failed: %v", err)
+	}
+	latest.Data["v"] = "2"
+	if err := underlying.Update(ctx, latest); err != nil {
+		t.Fatalf("update failed: %v", err)
+	}
+	if v, _ := read(); v != "1" {
+		t.Errorf("expected previous version while the update lags, got %q", v)
+	}
+	time.Sleep(delay)
+	if v, _ := read(); v != "2" {
+		t.Errorf("expected updated version after the lag, got %q", v)
+	}
+
+	var hidden, lagged int
+	for _, f := range faults(t, sink) {
+		if f.FaultType != event.FaultVisibilityDelay {
+			t.Errorf("unexpected fault type %s", f.FaultType)
+		}
+		if f.Detail == "hidden" {
+			hidden++
+		} else {
+			lagged++
+		}
+	}
+	if hidden != 2 || lagged != 2 {
+		t.Errorf("expected 2 hidden and 2 lagged faults (one per read), got %d and %d", hidden, lagged)
+	}
+}
+
+func TestDeprecatedVisibilityDelayMatchesKindName(t *testing.T) {
+	cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "cm", Namespace: "default", CreationTimestamp: metav1.Now()}}
+	c := Wrap(fake.NewClientBuilder().WithObjects(cm).Build()).
+		WithName("test-controller").
+		WithOptions(WithSink(NewMemorySink()), VisibilityDelay("ConfigMap", time.Hour))
+	ctx := WithReconcileID(context.Background(), "reconcile-1")
+
+	if err := c.Get(ctx, client.ObjectKeyFromObject(cm), &corev1.ConfigMap{}); !apierrors.IsNotFound(err) {
+		t.Errorf("expected newly created object to be hidden, got %v", err)
+	}
+}
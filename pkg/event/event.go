@@ -6,32 +6,109 @@ import (
 	"sort"
 
 	"github.com/tgoodwin/sleeve/pkg/snapshot"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
 )
 
 type Event struct {
-	Timestamp    string            `json:"timestamp"`
-	ReconcileID  string            `json:"reconcile_id"`
-	ControllerID string            `json:"controller_id"`
-	RootEventID  string            `json:"root_event_id"`
-	OpType       string            `json:"op_type"`
-	Kind         string            `json:"kind"`
-	ObjectID     string            `json:"object_id"`
-	Version      string            `json:"version"`
-	Labels       map[string]string `json:"labels,omitempty"`
+	Timestamp    string `json:"timestamp"`
+	ReconcileID  string `json:"reconcile_id"`
+	ControllerID string `json:"controller_id"`
+	RootEventID  string `json:"root_event_id"`
+	OpType       string `json:"op_type"`
+	Kind         string `json:"kind"`
+	ObjectID     string `json:"object_id"`
+	Version      string `json:"version"`
+	SubResource  string `json:"subresource,omitempty"`
+
+	// the Lamport clock value of the operation in the controller that performed it.
+	// Unlike the wall-clock Timestamp, it orders causally related operations across processes.
+	Clock uint64 `json:"clock,omitempty"`
+
+	// the apiVersion (group/version) of the object's kind
+	APIVersion string `json:"api_version,omitempty"`
+
+	// the object's name is known before it has a UID, which lets
+	// create events be linked to the objects they produce.
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name,omitempty"`
+
+	// set on reads that observed the absence of objects: a Get that returned NotFound
+	// (Namespace and Name are the requested key) or a List that returned no items.
+	Absent        bool   `json:"absent,omitempty"`
+	LabelSelector string `json:"label_selector,omitempty"`
+	FieldSelector string `json:"field_selector,omitempty"`
+
+	// for LIST events, the ListObservation that the event belongs to
+	ListID string `json:"list_id,omitempty"`
+
+	// for PATCH events, the patch that was sent. PatchData is omitted if the
+	// patch was larger than the client's size limit, but PatchSize is always set.
+	PatchType    string `json:"patch_type,omitempty"`
+	PatchData    string `json:"patch_data,omitempty"`
+	PatchSize    int    `json:"patch_size,omitempty"`
+	FieldManager string `json:"field_manager,omitempty"`
+	Force        bool   `json:"force,omitempty"`
+
+	// for UPDATE and PATCH events, the change that the controller made to the version of the
+	// object it last observed (BaseVersion), as JSON patch operations
+	BaseVersion string    `json:"base_version,omitempty"`
+	Delta       []DeltaOp `json:"delta,omitempty"`
+
+	// outcome of a write operation, recorded once the write has returned
+	Outcome        string `json:"outcome,omitempty"`
+	ErrorReason    string `json:"error_reason,omitempty"`
+	Error          string `json:"error,omitempty"`
+	ResultObjectID string `json:"result_object_id,omitempty"`
+	ResultVersion  string `json:"result_version,omitempty"`
+
+	// for subresource writes, the later version on which the sleeve labels were stamped
+	// (ResultVersion is the version that the write itself produced), or why they could not be
+	LabeledVersion string `json:"labeled_version,omitempty"`
+	LabelError     string `json:"label_error,omitempty"`
+
+	Labels map[string]string `json:"labels,omitempty"`
 }
 
+// DeltaOp is a JSON patch (RFC 6902) operation.
+type DeltaOp struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path"`
+	Value interface{} `json:"value"`
+}
+
+const (
+	OutcomeSuccess = "success"
+	OutcomeFailure = "failure"
+)
+
 // Ensure Event implements the json.Marshaler and json.Unmarshaler interfaces
 var _ json.Marshaler = (*Event)(nil)
 var _ json.Unmarshaler = (*Event)(nil)
 
 func (e *Event) CausalKey() CausalKey {
 	return CausalKey{
+		Group:    e.GroupVersionKind().Group,
 		Kind:     e.Kind,
 		ObjectID: e.ObjectID,
 		Version:  e.ChangeID(),
 	}
 }
 
+func (e *Event) GroupVersionKind() schema.GroupVersionKind {
+	return schema.FromAPIVersionAndKind(e.APIVersion, e.Kind)
+}
+
+func (e *Event) NamespacedName() types.NamespacedName {
+	return types.NamespacedName{Namespace: e.Namespace, Name: e.Name}
+}
+
+// Failed reports whether the event is a write that the apiserver rejected.
+// Events from traces that predate outcome recording are assumed to have succeeded.
+func (e *Event) Failed() bool {
+	return e.Outcome == OutcomeFailure
+}
+
 func (e *Event) ChangeID() ChangeID {
 	if changeID, ok := e.Labels["discrete.events/change-id"]; ok {
 		return ChangeID(changeID)
@@ -118,6 +195,7 @@ func (e Event) MarshalJSON() ([]byte, error) {
 
 func (e Event) VersionKey() snapshot.VersionKey {
 	return snapshot.VersionKey{
+		Group:    e.GroupVersionKind().Group,
 		Kind:     e.Kind,
 		ObjectID: e.ObjectID,
 		Version:  string(e.ChangeID()),
@@ -82,3 +82,13 @@ func TestMarshal(t *testing.T) {
 		t.Errorf("expected %v, got %v", expectedMap, actualMap)
 	}
 }
+
+func TestDeltaOpKeepsNullValues(t *testing.T) {
+	data, err := json.Marshal(DeltaOp{Op: "replace", Path: "/spec/replicas", Value: nil})
+	if err != nil {
+		t.Fatalf("failed to marshal delta op: %v", err)
+	}
+	if want := `{"op":"replace","path":"/spec/replicas","value":null}`; string(data) != want {
+		t.Errorf("got %s, want %s", data, want)
+	}
+}
@@ -0,0 +1,31 @@
+package event
+
+// FaultType identifies a kind of perturbation that sleeve injected into a controller's view of the world.
+type FaultType string
+
+const (
+	FaultStaleRead       FaultType = "stale-read"
+	FaultAPIError        FaultType = "api-error"
+	FaultVisibilityDelay FaultType = "visibility-delay"
+)
+
+// Fault records a single injected perturbation so that analysis can attribute
+// downstream behavior to the fault rather than to the controller.
+type Fault struct {
+	Timestamp    string    `json:"timestamp"`
+	ReconcileID  string    `json:"reconcile_id"`
+	ControllerID string    `json:"controller_id"`
+	FaultType    FaultType `json:"fault_type"`
+	OpType       string    `json:"op_type"`
+	Kind         string    `json:"kind"`
+	APIVersion   string    `json:"api_version,omitempty"`
+	ObjectID     string    `json:"object_id,omitempty"`
+	Namespace    string    `json:"namespace,omitempty"`
+	Name         string    `json:"name,omitempty"`
+
+	// the version the controller was served and the latest version sleeve knew of at the time
+	Version       string `json:"version,omitempty"`
+	LatestVersion string `json:"latest_version,omitempty"`
+
+	Detail string `json:"detail,omitempty"`
+}
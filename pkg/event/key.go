@@ -5,6 +5,7 @@ import (
 	"fmt"
 
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 )
 
 // ChangeID corresponds to the discrete.events/change-id label on a k8s object
@@ -15,13 +16,18 @@ type ChangeID string
 // lets us track the causal history of an object at "sleeve granularity", ignoring any object version changes
 // produced by k8s-internal controllers/mechanisms that are not instrumented by sleeve.
 type CausalKey struct {
+	Group    string
 	Kind     string
 	ObjectID string
 	Version  ChangeID
 }
 
 func (c CausalKey) String() string {
-	return fmt.Sprintf("%s:%s@%s", c.Kind, c.ObjectID, c.Version)
+	return fmt.Sprintf("%s:%s@%s", c.GroupKind(), c.ObjectID, c.Version)
+}
+
+func (c CausalKey) GroupKind() schema.GroupKind {
+	return schema.GroupKind{Group: c.Group, Kind: c.Kind}
 }
 
 func GetCausalKey(obj *unstructured.Unstructured) (CausalKey, error) {
@@ -31,6 +37,7 @@ func GetCausalKey(obj *unstructured.Unstructured) (CausalKey, error) {
 	}
 
 	k := CausalKey{
+		Group:    obj.GroupVersionKind().Group,
 		Kind:     obj.GetKind(),
 		ObjectID: string(obj.GetUID()),
 		Version:  cid,
@@ -0,0 +1,68 @@
+package event
+
+import "sort"
+
+// ObjectRef identifies an object by its kind and name. Unlike the UID,
+// it is known before the object has been created.
+type ObjectRef struct {
+	Group     string
+	Kind      string
+	Namespace string
+	Name      string
+}
+
+func (e *Event) ObjectRef() ObjectRef {
+	return ObjectRef{
+		Group:     e.GroupVersionKind().Group,
+		Kind:      e.Kind,
+		Namespace: e.Namespace,
+		Name:      e.Name,
+	}
+}
+
+// LinkCreates fills in the ObjectID of create events, which have no UID at the time they are emitted.
+// The UID is taken from the outcome of the create if it was recorded, otherwise from the earliest
+// subsequent event on an object with the same kind and name.
+func LinkCreates(events []*Event) {
+	ordered := make([]*Event, len(events))
+	copy(ordered, events)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return before(ordered[i], ordered[j])
+	})
+
+	// creates that are still waiting for a later event to reveal their object's UID
+	pending := make(map[ObjectRef][]*Event)
+	for _, e := range ordered {
+		if e.OpType == "CREATE" && e.ObjectID == "" {
+			if e.ResultObjectID != "" {
+				e.ObjectID = e.ResultObjectID
+				continue
+			}
+			if e.Name != "" {
+				pending[e.ObjectRef()] = append(pending[e.ObjectRef()], e)
+			}
+			continue
+		}
+		if e.ObjectID == "" || e.Name == "" {
+			continue
+		}
+		ref := e.ObjectRef()
+		for _, create := range pending[ref] {
+			create.ObjectID = e.ObjectID
+		}
+		delete(pending, ref)
+	}
+}
+
+// before orders events by their logical clock, which unlike wall-clock time is consistent with causality
+// across processes, and by timestamp where the clocks tie. Events from traces that predate logical clocks
+// cannot be placed among clocked events, so they follow them.
+func before(a, b *Event) bool {
+	if (a.Clock == 0) != (b.Clock == 0) {
+		return b.Clock == 0
+	}
+	if a.Clock != b.Clock {
+		return a.Clock < b.Clock
+	}
+	return a.Timestamp < b.Timestamp
+}
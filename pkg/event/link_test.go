@@ -0,0 +1,32 @@
+package event
+
+import "testing"
+
+func TestLinkCreates(t *testing.T) {
+	create := &Event{Timestamp: "1", OpType: "CREATE", APIVersion: "apps/v1", Kind: "Deployment", Namespace: "default", Name: "web"}
+	createWithResult := &Event{Timestamp: "1", OpType: "CREATE", Kind: "ConfigMap", Namespace: "default", Name: "cfg", ResultObjectID: "uid-cfg"}
+	otherGroup := &Event{Timestamp: "2", OpType: "GET", APIVersion: "example.com/v1", Kind: "Deployment", Namespace: "default", Name: "web", ObjectID: "uid-other"}
+	read := &Event{Timestamp: "3", OpType: "GET", APIVersion: "apps/v1", Kind: "Deployment", Namespace: "default", Name: "web", ObjectID: "uid-web"}
+
+	LinkCreates([]*Event{read, otherGroup, create, createWithResult})
+
+	if create.ObjectID != "uid-web" {
+		t.Errorf("expected create to be linked to uid-web, got %q", create.ObjectID)
+	}
+	if createWithResult.ObjectID != "uid-cfg" {
+		t.Errorf("expected create to take its result object ID, got %q", createWithResult.ObjectID)
+	}
+}
+
+func TestLinkCreatesOrdersByClock(t *testing.T) {
+	// the process that read the object's previous incarnation has a clock that runs ahead
+	previous := &Event{Timestamp: "4", Clock: 2, OpType: "GET", Kind: "ConfigMap", Namespace: "default", Name: "cfg", ObjectID: "uid-old"}
+	create := &Event{Timestamp: "3", Clock: 5, OpType: "CREATE", Kind: "ConfigMap", Namespace: "default", Name: "cfg"}
+	read := &Event{Timestamp: "5", Clock: 6, OpType: "GET", Kind: "ConfigMap", Namespace: "default", Name: "cfg", ObjectID: "uid-new"}
+
+	LinkCreates([]*Event{read, create, previous})
+
+	if create.ObjectID != "uid-new" {
+		t.Errorf("expected create to be linked to uid-new, got %q", create.ObjectID)
+	}
+}
@@ -0,0 +1,49 @@
+package event
+
+import "github.com/tgoodwin/sleeve/pkg/snapshot"
+
+// ListObservation records a single List call. The items it returned are observed atomically,
+// and are also logged as individual LIST events that carry the ListID of the observation.
+type ListObservation struct {
+	Timestamp    string `json:"timestamp"`
+	ReconcileID  string `json:"reconcile_id"`
+	ControllerID string `json:"controller_id"`
+	ListID       string `json:"list_id"`
+	Kind         string `json:"kind"`
+	APIVersion   string `json:"api_version,omitempty"`
+
+	// the options the list was issued with
+	Namespace     string `json:"namespace,omitempty"`
+	LabelSelector string `json:"label_selector,omitempty"`
+	FieldSelector string `json:"field_selector,omitempty"`
+	Limit         int64  `json:"limit,omitempty"`
+	Continue      string `json:"continue,omitempty"`
+
+	// the resourceVersion of the list itself
+	ResourceVersion string     `json:"resource_version"`
+	Items           []ListItem `json:"items"`
+}
+
+// ListItem identifies an object version returned by a List call.
+type ListItem struct {
+	ObjectID    string `json:"object_id"`
+	Namespace   string `json:"namespace,omitempty"`
+	Name        string `json:"name"`
+	Version     string `json:"version"`
+	ChangeID    string `json:"change_id,omitempty"`
+	RootEventID string `json:"root_event_id,omitempty"`
+}
+
+// Observation returns the set of object versions that the list observed.
+func (o *ListObservation) Observation() snapshot.Observation {
+	elements := make(snapshot.VersionSet)
+	for _, item := range o.Items {
+		elements[snapshot.ObjectVersion{
+			Kind:    o.Kind,
+			Uid:     item.ObjectID,
+			Version: item.Version,
+			TraceID: item.RootEventID,
+		}] = struct{}{}
+	}
+	return snapshot.NewObservation(o.Timestamp, elements)
+}
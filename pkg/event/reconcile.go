@@ -0,0 +1,26 @@
+package event
+
+type ReconcilePhase string
+
+const (
+	ReconcileBegin ReconcilePhase = "begin"
+	ReconcileEnd   ReconcilePhase = "end"
+)
+
+// Reconcile marks the beginning or end of a reconcile invocation.
+type Reconcile struct {
+	Timestamp    string         `json:"timestamp"`
+	ReconcileID  string         `json:"reconcile_id"`
+	ControllerID string         `json:"controller_id"`
+	Phase        ReconcilePhase `json:"phase"`
+
+	// the reconcile.Request the reconciler was invoked with
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+
+	// the outcome of the invocation, set on end records only
+	Requeue      bool   `json:"requeue,omitempty"`
+	RequeueAfter string `json:"requeue_after,omitempty"`
+	Error        string `json:"error,omitempty"`
+	Duration     string `json:"duration,omitempty"`
+}
@@ -0,0 +1,16 @@
+package event
+
+// RootEvent records a change made by a user rather than by a controller. The tracey-uid
+// that the webhook assigns to the object is the RootEventID of every controller operation
+// that follows from the change.
+type RootEvent struct {
+	Timestamp   string `json:"timestamp"`
+	RootEventID string `json:"root_event_id"`
+	OpType      string `json:"op_type"`
+	Kind        string `json:"kind"`
+	APIVersion  string `json:"api_version,omitempty"`
+	ObjectID    string `json:"object_id,omitempty"`
+	Namespace   string `json:"namespace,omitempty"`
+	Name        string `json:"name,omitempty"`
+	User        string `json:"user"`
+}
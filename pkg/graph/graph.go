@@ -19,6 +19,8 @@ var readOps map[client.OperationType]struct{} = map[client.OperationType]struct{
 }
 
 func BackfillLabels(events []*event.Event) []*event.Event {
+	event.LinkCreates(events)
+
 	readEvents := lo.Filter(events, func(e *event.Event, _ int) bool {
 		_, ok := readOps[client.OperationType(e.OpType)]
 		return ok
@@ -1,20 +1,32 @@
 package replay
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
+	"io"
 	"sort"
 	"strings"
 
 	"github.com/samber/lo"
 	"github.com/tgoodwin/sleeve/pkg/event"
+	"github.com/tgoodwin/sleeve/pkg/snapshot"
+	"github.com/tgoodwin/sleeve/pkg/trace"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 )
 
 func ParseTrace(traceData []byte) (*Builder, error) {
+	return ReadTrace(bytes.NewReader(traceData))
+}
+
+// ReadTrace loads a trace from r. The trace is streamed, so only its sleeve records are held in memory.
+func ReadTrace(r io.Reader) (*Builder, error) {
 	b := &Builder{}
-	if err := b.fromTrace(traceData); err != nil {
+	if err := b.readTrace(r); err != nil {
 		return nil, err
 	}
 	return b, nil
@@ -36,30 +48,79 @@ type Builder struct {
 	// controller operations found in the trace
 	events []event.Event
 
+	// reconcile begin records found in the trace, keyed by reconcileID.
+	// Only present for reconcilers wrapped with WrapReconciler.
+	reconcileBegins map[string]event.Reconcile
+
+	// list calls found in the trace
+	listObservations []event.ListObservation
+
 	// for bookkeeping and validation
 	reconcilerIDs map[string]struct{}
+
+	// whether the trace holds every record that was emitted
+	integrity *trace.IntegrityReport
 }
 
-func (b *Builder) fromTrace(traceData []byte) error {
-	rs := newReplayStore()
-	if err := rs.HydrateFromTrace(traceData); err != nil {
-		return err
+// readTrace loads the records of a trace in a single pass.
+func (b *Builder) readTrace(r io.Reader) error {
+	b.replayStore = newReplayStore()
+	b.reconcilerIDs = make(map[string]struct{})
+	b.reconcileBegins = make(map[string]event.Reconcile)
+
+	events := make([]event.Event, 0)
+	snapshots := 0
+	reader := trace.NewReader(r)
+	for {
+		rec, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		var decodeErr *trace.DecodeError
+		if errors.As(err, &decodeErr) {
+			// reported by the integrity check
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("reading trace: %w", err)
+		}
+		switch {
+		case rec.Event != nil:
+			events = append(events, *rec.Event)
+		case rec.Snapshot != nil:
+			snapshots++
+			if err := b.replayStore.Add(*rec.Snapshot); err != nil {
+				fmt.Printf("error adding record to store: %v\n", rec.Snapshot.ObjectID)
+			}
+		case rec.Reconcile != nil:
+			if rec.Reconcile.Phase == event.ReconcileBegin {
+				b.reconcileBegins[rec.Reconcile.ReconcileID] = *rec.Reconcile
+			}
+		case rec.ListObservation != nil:
+			b.listObservations = append(b.listObservations, *rec.ListObservation)
+		}
 	}
-	b.replayStore = rs
+	fmt.Println("total record observations in trace", snapshots)
+	fmt.Println("unique records in store after hydration", len(b.store))
+	fmt.Println("total events", len(events))
 
-	// track all reconciler IDs in the trace
-	b.reconcilerIDs = make(map[string]struct{})
+	b.integrity = reader.Integrity()
+	if !b.integrity.Intact() {
+		fmt.Printf("WARNING: trace is incomplete, analysis results may not be reliable\n%s", b.integrity)
+	}
 
-	lines := strings.Split(string(traceData), "\n")
-	events, err := ParseEventsFromLines(lines)
-	if err != nil {
-		return err
+	// creates carry no UID, so link them to the objects they produced
+	eventPtrs := make([]*event.Event, len(events))
+	for i := range events {
+		eventPtrs[i] = &events[i]
 	}
-	fmt.Println("total events", len(events))
+	event.LinkCreates(eventPtrs)
+	b.resolveAPIVersions(events)
 
 	// filter events to only include those that are reads
+	// absences have no object to look up
 	readEvents := lo.Filter(events, func(e event.Event, _ int) bool {
-		return e.OpType == "GET" || e.OpType == "LIST"
+		return (e.OpType == "GET" || e.OpType == "LIST") && !e.Absent
 	})
 
 	// for each read event, sanity check that the object is in the store
@@ -74,6 +135,7 @@ func (b *Builder) fromTrace(traceData []byte) error {
 	}
 
 	b.events = events
+
 	for controllerID := range b.reconcilerIDs {
 		fmt.Println("Found controllerID in trace", controllerID)
 	}
@@ -81,6 +143,11 @@ func (b *Builder) fromTrace(traceData []byte) error {
 	return nil
 }
 
+// Integrity reports whether records were lost, duplicated, reordered or truncated on their way into the trace.
+func (b *Builder) Integrity() *trace.IntegrityReport {
+	return b.integrity
+}
+
 func (b *Builder) BuildHarness(controllerID string) (*ReplayHarness, error) {
 	if _, ok := b.reconcilerIDs[controllerID]; !ok {
 		return nil, fmt.Errorf("controllerID not found in trace: %s", controllerID)
@@ -100,12 +167,16 @@ func (b *Builder) BuildHarness(controllerID string) (*ReplayHarness, error) {
 	for reconcileID, events := range byReconcileID {
 
 		reads, writes := event.FilterReadsWrites(events)
-		effects[reconcileID] = DataEffect{Reads: reads, Writes: writes}
-		req, err := b.inferReconcileRequestFromReadset(controllerID, reads)
+		// writes that the apiserver rejected had no effect on the world
+		writes = lo.Filter(writes, func(e event.Event, _ int) bool {
+			return !e.Failed()
+		})
+		effects[reconcileID] = DataEffect{Reads: reads, Writes: writes, Written: b.writtenObjects(writes)}
+		req, err := b.reconcileRequest(controllerID, reconcileID, reads)
 		if err != nil {
 			return nil, err
 		}
-		cacheFrame, err := b.generateCacheFrame(reads)
+		cacheFrame, err := b.generateCacheFrame(events)
 		if err != nil {
 			return nil, err
 		}
@@ -113,42 +184,204 @@ func (b *Builder) BuildHarness(controllerID string) (*ReplayHarness, error) {
 
 		rootEventID := getRootIDFromEvents(events)
 
-		// TODO revisit this
-		earliestTs := events[0].Timestamp
-
-		frames = append(frames, Frame{Type: FrameTypeTraced, ID: reconcileID, Req: req, sequenceID: earliestTs, TraceyRootID: rootEventID})
+		frames = append(frames, Frame{Type: FrameTypeTraced, ID: reconcileID, Req: req, sequence: b.reconcileSequence(reconcileID, events), TraceyRootID: rootEventID})
 	}
 
-	// sort the frames by sequenceID
-	sort.Slice(frames, func(i, j int) bool {
-		return frames[i].sequenceID < frames[j].sequenceID
+	sort.SliceStable(frames, func(i, j int) bool {
+		return frames[i].sequence.before(frames[j].sequence)
 	})
 
 	harness := newHarness(controllerID, frames, FrameData, effects)
 	return harness, nil
 }
 
+// reconcileSequence returns the position of a reconcile in the trace: the logical clock of its first operation,
+// and the time at which it began.
+func (b *Builder) reconcileSequence(reconcileID string, events []event.Event) sequence {
+	seq := sequence{timestamp: events[0].Timestamp}
+	if begin, ok := b.reconcileBegins[reconcileID]; ok {
+		seq.timestamp = begin.Timestamp
+	}
+	for _, e := range events {
+		if e.Clock != 0 && (seq.clock == 0 || e.Clock < seq.clock) {
+			seq.clock = e.Clock
+		}
+	}
+	return seq
+}
+
+// Observations returns the object versions that the controller observed through List calls, in order.
+func (b *Builder) Observations(controllerID string) snapshot.LocalKnowledge {
+	observations := lo.Filter(b.listObservations, func(o event.ListObservation, _ int) bool {
+		return o.ControllerID == controllerID
+	})
+	sort.SliceStable(observations, func(i, j int) bool {
+		return observations[i].Timestamp < observations[j].Timestamp
+	})
+	return lo.Map(observations, func(o event.ListObservation, _ int) snapshot.Observation {
+		return o.Observation()
+	})
+}
+
+// writtenObjects returns the objects that the writes produced, as far as the trace holds them.
+// The version of an object that a write produced carries the change ID of the write.
+func (b *Builder) writtenObjects(writes []event.Event) map[int]*unstructured.Unstructured {
+	written := make(map[int]*unstructured.Unstructured)
+	for i, e := range writes {
+		if e.OpType == "DELETE" {
+			continue
+		}
+		if obj, ok := b.store[e.CausalKey()]; ok {
+			written[i] = obj
+		}
+	}
+	return written
+}
+
+// generateCacheFrame returns the objects that a reconcile read, given all of its events in trace order.
 func (r *Builder) generateCacheFrame(events []event.Event) (FrameData, error) {
 	cacheFrame := make(FrameData)
-	for _, e := range events {
+	// an object that the reconcile read after observing its absence is left out of the frame if the reconcile
+	// itself wrote the version it read after the absence, so the replayed reconcile observes the absence too.
+	// Objects that another actor created in the meantime stay in the frame.
+	absences := make([]int, 0)
+	for i, e := range events {
+		if !event.IsReadOp(e) {
+			continue
+		}
+		if e.Absent {
+			absences = append(absences, i)
+			continue
+		}
 		key := e.CausalKey()
-		if obj, ok := r.store[key]; ok {
-			if _, ok := cacheFrame[e.Kind]; !ok {
-				cacheFrame[e.Kind] = make(map[types.NamespacedName]*unstructured.Unstructured)
-			}
-			cacheFrame[e.Kind][types.NamespacedName{Namespace: obj.GetNamespace(), Name: obj.GetName()}] = obj
-		} else {
+		obj, ok := r.store[key]
+		if !ok {
 			return nil, fmt.Errorf("generating cache frame: object not found in store: %#v", key)
 		}
+		if lo.ContainsBy(absences, func(a int) bool {
+			return observedAbsent(events[a], obj) && writtenAfter(events, e.ChangeID(), a)
+		}) {
+			continue
+		}
+		gk := obj.GroupVersionKind().GroupKind()
+		if _, ok := cacheFrame[gk]; !ok {
+			cacheFrame[gk] = make(map[types.NamespacedName]*unstructured.Unstructured)
+		}
+		cacheFrame[gk][types.NamespacedName{Namespace: obj.GetNamespace(), Name: obj.GetName()}] = obj
 	}
 	return cacheFrame, nil
 }
 
+// writtenAfter reports whether one of the events is a successful write that produced the given version
+// after the event at index a. Events are ordered by their logical clocks where both carry one, and by
+// their position in the trace otherwise.
+func writtenAfter(events []event.Event, version event.ChangeID, a int) bool {
+	for i, w := range events {
+		if event.IsReadOp(w) || w.Failed() || w.ChangeID() != version {
+			continue
+		}
+		if w.Clock != 0 && events[a].Clock != 0 {
+			if w.Clock > events[a].Clock {
+				return true
+			}
+		} else if i > a {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveAPIVersions fills in the apiVersion of events from traces that only recorded a Kind,
+// using the apiVersion of the recorded object they refer to, so that they key into the store.
+func (b *Builder) resolveAPIVersions(events []event.Event) {
+	type objectKind struct {
+		kind     string
+		objectID string
+	}
+	apiVersions := make(map[objectKind]string)
+	for _, obj := range b.store {
+		apiVersions[objectKind{obj.GetKind(), string(obj.GetUID())}] = obj.GetAPIVersion()
+	}
+	for i := range events {
+		e := &events[i]
+		if e.APIVersion != "" {
+			continue
+		}
+		if apiVersion, ok := apiVersions[objectKind{e.Kind, e.ObjectID}]; ok {
+			e.APIVersion = apiVersion
+		}
+	}
+}
+
+// observedAbsent reports whether the absence observed by a read covers the object.
+func observedAbsent(absence event.Event, obj *unstructured.Unstructured) bool {
+	if absence.Kind != obj.GetKind() || (absence.APIVersion != "" && absence.GroupVersionKind().Group != obj.GroupVersionKind().Group) {
+		return false
+	}
+	if absence.Namespace != "" && absence.Namespace != obj.GetNamespace() {
+		return false
+	}
+	if absence.OpType == "GET" {
+		return absence.Name == obj.GetName()
+	}
+	if absence.LabelSelector != "" {
+		selector, err := labels.Parse(absence.LabelSelector)
+		if err != nil || !selector.Matches(labels.Set(obj.GetLabels())) {
+			return false
+		}
+	}
+	if absence.FieldSelector != "" {
+		selector, err := fields.ParseSelector(absence.FieldSelector)
+		if err != nil {
+			return false
+		}
+		set, ok := fieldSet(selector, obj)
+		if !ok || !selector.Matches(set) {
+			return false
+		}
+	}
+	return true
+}
+
+// fieldSet returns the values of the fields that the selector refers to. It reports false if one of them is
+// not a string field of the object, in which case the selector cannot be evaluated against it.
+func fieldSet(selector fields.Selector, obj *unstructured.Unstructured) (fields.Set, bool) {
+	set := make(fields.Set)
+	for _, req := range selector.Requirements() {
+		switch req.Field {
+		case "metadata.name":
+			set[req.Field] = obj.GetName()
+		case "metadata.namespace":
+			set[req.Field] = obj.GetNamespace()
+		default:
+			value, found, err := unstructured.NestedString(obj.Object, strings.Split(req.Field, ".")...)
+			if !found || err != nil {
+				return nil, false
+			}
+			set[req.Field] = value
+		}
+	}
+	return set, true
+}
+
+// reconcileRequest returns the reconcile.Request that a reconcile was invoked with. If the trace has a
+// reconcile begin record for it, the request is taken from there, otherwise it is inferred from the readset.
+func (r *Builder) reconcileRequest(controllerID, reconcileID string, readset []event.Event) (reconcile.Request, error) {
+	if begin, ok := r.reconcileBegins[reconcileID]; ok {
+		return reconcile.Request{NamespacedName: types.NamespacedName{Namespace: begin.Namespace, Name: begin.Name}}, nil
+	}
+	return r.inferReconcileRequestFromReadset(controllerID, readset)
+}
+
 func (r *Builder) inferReconcileRequestFromReadset(controllerID string, readset []event.Event) (reconcile.Request, error) {
 	for _, e := range readset {
 		// Assumption: reconcile routines are invoked upon a Resource that shares the same name (Kind)
 		// as the controller that is managing it.
 		if e.Kind == controllerID {
+			if e.Name != "" {
+				return reconcile.Request{NamespacedName: e.NamespacedName()}, nil
+			}
+			// traces that predate names on events
 			if obj, ok := r.store[e.CausalKey()]; ok {
 				name := obj.GetName()
 				namespace := obj.GetNamespace()
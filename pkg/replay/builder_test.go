@@ -0,0 +1,274 @@
+package replay
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	sleeveclient "github.com/tgoodwin/sleeve/pkg/client"
+	"github.com/tgoodwin/sleeve/pkg/tag"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// toTrace renders the records in the sink the way zap's console encoder writes them to controller logs.
+func toTrace(sink *sleeveclient.MemorySink) []byte {
+	var b strings.Builder
+	for _, r := range sink.Records() {
+		fmt.Fprintf(&b, "2024-01-01T00:00:00Z\tINFO\t%s\t%s\t{\"LogType\": \"%s\"}\n", tag.LoggerName, r.Payload, r.LogType)
+	}
+	return []byte(b.String())
+}
+
+func TestBuildHarnessUsesReconcileRecords(t *testing.T) {
+	cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{
+		Name:      "config",
+		Namespace: "default",
+		Labels:    map[string]string{tag.TraceyWebhookLabel: "root-1"},
+	}}
+	sink := sleeveclient.NewMemorySink()
+	c := sleeveclient.Wrap(fake.NewClientBuilder().WithObjects(cm).Build()).
+		WithName("test-controller").
+		WithOptions(sleeveclient.WithSink(sink), sleeveclient.LogObjectSnapshots())
+
+	// the request does not name any object that the reconciler reads,
+	// so it can only be recovered from the reconcile records
+	r := sleeveclient.WrapReconciler("test-controller", reconcile.Func(func(ctx context.Context, req reconcile.Request) (reconcile.Result, error) {
+		return reconcile.Result{}, c.Get(ctx, client.ObjectKeyFromObject(cm), &corev1.ConfigMap{})
+	}), sleeveclient.WithSink(sink))
+
+	req := reconcile.Request{NamespacedName: types.NamespacedName{Namespace: "default", Name: "target"}}
+	if _, err := r.Reconcile(context.Background(), req); err != nil {
+		t.Fatalf("reconcile failed: %v", err)
+	}
+
+	b, err := ParseTrace(toTrace(sink))
+	if err != nil {
+		t.Fatalf("failed to parse trace: %v", err)
+	}
+	h, err := b.BuildHarness("test-controller")
+	if err != nil {
+		t.Fatalf("failed to build harness: %v", err)
+	}
+	if len(h.frames) != 1 {
+		t.Fatalf("expected 1 frame, got %d", len(h.frames))
+	}
+	if h.frames[0].Req != req {
+		t.Errorf("expected request %v, got %v", req, h.frames[0].Req)
+	}
+}
+
+func TestReadTraceFromFileSink(t *testing.T) {
+	cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{
+		Name:      "config",
+		Namespace: "default",
+		Labels:    map[string]string{tag.TraceyWebhookLabel: "root-1"},
+	}}
+	path := filepath.Join(t.TempDir(), "trace.jsonl")
+	sink, err := sleeveclient.NewFileSink(path, 0, 0)
+	if err != nil {
+		t.Fatalf("failed to create file sink: %v", err)
+	}
+	// named after the kind it reconciles, so that the request can be inferred from the readset
+	c := sleeveclient.Wrap(fake.NewClientBuilder().WithObjects(cm).Build()).
+		WithName("ConfigMap").
+		WithOptions(sleeveclient.WithSink(sink), sleeveclient.LogObjectSnapshots())
+	ctx := sleeveclient.WithReconcileID(context.Background(), "reconcile-1")
+	if err := c.Get(ctx, client.ObjectKeyFromObject(cm), &corev1.ConfigMap{}); err != nil {
+		t.Fatalf("get failed: %v", err)
+	}
+	sink.Close()
+
+	// the file sink writes zap's JSON format rather than the console format
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("failed to open trace: %v", err)
+	}
+	defer f.Close()
+	b, err := ReadTrace(f)
+	if err != nil {
+		t.Fatalf("failed to read trace: %v", err)
+	}
+	if len(b.events) != 1 || len(b.store) != 1 {
+		t.Fatalf("expected 1 event and 1 snapshot, got %d and %d", len(b.events), len(b.store))
+	}
+	if _, err := b.BuildHarness("ConfigMap"); err != nil {
+		t.Errorf("failed to build harness: %v", err)
+	}
+}
+
+func TestBuildHarnessReproducesAbsences(t *testing.T) {
+	sink := sleeveclient.NewMemorySink()
+	c := sleeveclient.Wrap(fake.NewClientBuilder().Build()).
+		WithName("test-controller").
+		WithOptions(sleeveclient.WithSink(sink), sleeveclient.LogObjectSnapshots())
+
+	key := types.NamespacedName{Namespace: "default", Name: "config"}
+	r := sleeveclient.WrapReconciler("test-controller", reconcile.Func(func(ctx context.Context, req reconcile.Request) (reconcile.Result, error) {
+		if err := c.Get(ctx, key, &corev1.ConfigMap{}); !apierrors.IsNotFound(err) {
+			return reconcile.Result{}, fmt.Errorf("expected NotFound, got %v", err)
+		}
+		cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: key.Name, Namespace: key.Namespace}}
+		if err := c.Create(ctx, cm); err != nil {
+			return reconcile.Result{}, err
+		}
+		return reconcile.Result{}, c.Get(ctx, key, &corev1.ConfigMap{})
+	}), sleeveclient.WithSink(sink))
+
+	if _, err := r.Reconcile(context.Background(), reconcile.Request{NamespacedName: key}); err != nil {
+		t.Fatalf("reconcile failed: %v", err)
+	}
+
+	b, err := ParseTrace(toTrace(sink))
+	if err != nil {
+		t.Fatalf("failed to parse trace: %v", err)
+	}
+	h, err := b.BuildHarness("test-controller")
+	if err != nil {
+		t.Fatalf("failed to build harness: %v", err)
+	}
+	frameID := h.frames[0].ID
+	if objs, ok := h.frameDataByFrameID[frameID].objectsFor(schema.GroupKind{Kind: "ConfigMap"}); ok && objs[key] != nil {
+		t.Fatalf("expected the object created by the reconcile to be left out of the frame")
+	}
+
+	recorder := &Recorder{reconcilerID: "test-controller", effectContainer: make(map[string]DataEffect)}
+	replayClient := NewClient(nil, h.frameDataByFrameID, recorder)
+	err = replayClient.Get(WithFrameID(context.Background(), frameID), key, &corev1.ConfigMap{})
+	if !apierrors.IsNotFound(err) {
+		t.Fatalf("expected replayed get to return NotFound, got %v", err)
+	}
+	reads := recorder.effectContainer[frameID].Reads
+	if len(reads) != 1 || !reads[0].Absent || reads[0].Name != key.Name {
+		t.Errorf("expected replayed absence to be recorded, got %+v", reads)
+	}
+}
+
+func TestReplayListHonorsSelectors(t *testing.T) {
+	root := map[string]string{tag.TraceyWebhookLabel: "root-1"}
+	withApp := func(app string) map[string]string {
+		return map[string]string{tag.TraceyWebhookLabel: "root-1", "app": app}
+	}
+	objs := []client.Object{
+		&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "a", UID: "uid-a", Namespace: "default", Labels: withApp("web")}},
+		&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "b", UID: "uid-b", Namespace: "default", Labels: withApp("db")}},
+		&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "c", UID: "uid-c", Namespace: "other", Labels: root}},
+	}
+	sink := sleeveclient.NewMemorySink()
+	c := sleeveclient.Wrap(fake.NewClientBuilder().WithObjects(objs...).Build()).
+		WithName("test-controller").
+		WithOptions(sleeveclient.WithSink(sink), sleeveclient.LogObjectSnapshots())
+
+	r := sleeveclient.WrapReconciler("test-controller", reconcile.Func(func(ctx context.Context, req reconcile.Request) (reconcile.Result, error) {
+		if err := c.List(ctx, &corev1.ConfigMapList{}); err != nil {
+			return reconcile.Result{}, err
+		}
+		return reconcile.Result{}, c.List(ctx, &corev1.ConfigMapList{}, client.InNamespace("default"), client.MatchingLabels{"app": "web"})
+	}), sleeveclient.WithSink(sink))
+	if _, err := r.Reconcile(context.Background(), reconcile.Request{}); err != nil {
+		t.Fatalf("reconcile failed: %v", err)
+	}
+
+	b, err := ParseTrace(toTrace(sink))
+	if err != nil {
+		t.Fatalf("failed to parse trace: %v", err)
+	}
+	observations := b.Observations("test-controller")
+	if len(observations) != 2 || len(observations[0].Elements()) != 3 || len(observations[1].Elements()) != 1 {
+		t.Fatalf("unexpected observations: %+v", observations)
+	}
+
+	h, err := b.BuildHarness("test-controller")
+	if err != nil {
+		t.Fatalf("failed to build harness: %v", err)
+	}
+	frameID := h.frames[0].ID
+	recorder := &Recorder{reconcilerID: "test-controller", effectContainer: make(map[string]DataEffect)}
+	replayClient := NewClient(nil, h.frameDataByFrameID, recorder)
+	list := &corev1.ConfigMapList{}
+	if err := replayClient.List(WithFrameID(context.Background(), frameID), list, client.InNamespace("default"), client.MatchingLabels{"app": "web"}); err != nil {
+		t.Fatalf("replayed list failed: %v", err)
+	}
+	if len(list.Items) != 1 || list.Items[0].Name != "a" {
+		t.Errorf("expected only default/a, got %+v", list.Items)
+	}
+}
+
+func TestBuildHarnessKeepsObjectsTheAbsenceDoesNotCover(t *testing.T) {
+	key := types.NamespacedName{Namespace: "default", Name: "config"}
+	tests := []struct {
+		name      string
+		reconcile func(ctx context.Context, c client.Client, other client.Client) error
+	}{
+		{
+			name: "created by another actor after the absence",
+			reconcile: func(ctx context.Context, c client.Client, other client.Client) error {
+				if err := c.Get(ctx, key, &corev1.ConfigMap{}); !apierrors.IsNotFound(err) {
+					return fmt.Errorf("expected NotFound, got %v", err)
+				}
+				cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: key.Name, Namespace: key.Namespace}}
+				if err := other.Create(ctx, cm); err != nil {
+					return err
+				}
+				return c.Get(ctx, key, &corev1.ConfigMap{})
+			},
+		},
+		{
+			name: "outside the field selector of the empty list",
+			reconcile: func(ctx context.Context, c client.Client, other client.Client) error {
+				if err := c.List(ctx, &corev1.ConfigMapList{}, client.MatchingFields{"metadata.name": "unrelated"}); err != nil {
+					return err
+				}
+				cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: key.Name, Namespace: key.Namespace}}
+				if err := c.Create(ctx, cm); err != nil {
+					return err
+				}
+				return c.Get(ctx, key, &corev1.ConfigMap{})
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sink := sleeveclient.NewMemorySink()
+			underlying := fake.NewClientBuilder().
+				WithIndex(&corev1.ConfigMap{}, "metadata.name", func(o client.Object) []string { return []string{o.GetName()} }).
+				Build()
+			// the other actor is traced too, so that the version it creates is recorded
+			other := sleeveclient.Wrap(underlying).
+				WithName("other-controller").
+				WithOptions(sleeveclient.WithSink(sink), sleeveclient.LogObjectSnapshots())
+			c := sleeveclient.Wrap(underlying).
+				WithName("test-controller").
+				WithOptions(sleeveclient.WithSink(sink), sleeveclient.LogObjectSnapshots())
+			r := sleeveclient.WrapReconciler("test-controller", reconcile.Func(func(ctx context.Context, req reconcile.Request) (reconcile.Result, error) {
+				return reconcile.Result{}, tt.reconcile(ctx, c, other)
+			}), sleeveclient.WithSink(sink))
+
+			if _, err := r.Reconcile(context.Background(), reconcile.Request{NamespacedName: key}); err != nil {
+				t.Fatalf("reconcile failed: %v", err)
+			}
+
+			b, err := ParseTrace(toTrace(sink))
+			if err != nil {
+				t.Fatalf("failed to parse trace: %v", err)
+			}
+			h, err := b.BuildHarness("test-controller")
+			if err != nil {
+				t.Fatalf("failed to build harness: %v", err)
+			}
+			objs, _ := h.frameDataByFrameID[h.frames[0].ID].objectsFor(schema.GroupKind{Kind: "ConfigMap"})
+			if objs[key] == nil {
+				t.Errorf("expected the object to be kept in the frame")
+			}
+		})
+	}
+}
@@ -2,16 +2,26 @@ package replay
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"reflect"
+	"sort"
+	"strings"
 
 	"github.com/go-logr/logr"
+	"github.com/samber/lo"
 	sleeveclient "github.com/tgoodwin/sleeve/pkg/client"
+	"github.com/tgoodwin/sleeve/pkg/util"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/selection"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 )
 
@@ -19,46 +29,190 @@ var logger logr.Logger
 
 type EffectRecorder interface {
 	RecordEffect(ctx context.Context, obj client.Object, opType sleeveclient.OperationType) error
+	// RecordAbsence records a read that observed that no object exists.
+	RecordAbsence(ctx context.Context, gvk schema.GroupVersionKind, key client.ObjectKey, listOpts *client.ListOptions, opType sleeveclient.OperationType) error
+	// RecordSubResourceEffect records a write to a subresource of obj, e.g. its status.
+	RecordSubResourceEffect(ctx context.Context, obj client.Object, subResource string, opType sleeveclient.OperationType) error
 }
 
 type Client struct {
-	// dummyClient is a useless type that implements the remainder of the client.Client interface
-	*dummyClient
-	framesByID     map[string]frameData
+	framesByID     map[string]FrameData
 	effectRecorder EffectRecorder
 
-	scheme *runtime.Scheme
+	// field indexers registered through IndexField, for List calls with field selectors
+	indexers map[schema.GroupKind]map[string]fieldIndexer
+
+	scheme     *runtime.Scheme
+	restMapper meta.RESTMapper
+
+	// the shadow state of each frame, keyed by frameID. Nil unless shadow state is enabled.
+	shadow map[string]*shadowState
+}
+
+type fieldIndexer struct {
+	// the object type that the indexer was registered for, which the indexer is called with
+	objType reflect.Type
+	extract client.IndexerFunc
 }
 
-func NewClient(scheme *runtime.Scheme, frameData map[string]frameData, effectRecorder EffectRecorder) *Client {
+// NewClient returns a client that serves the reconciler the objects of the frames it replays.
+// The scheme should hold the types the reconciler uses; if nil, the client-go scheme of built-in types is used.
+func NewClient(scheme *runtime.Scheme, frameData map[string]FrameData, effectRecorder EffectRecorder) *Client {
+	if scheme == nil {
+		scheme = clientgoscheme.Scheme
+	}
 	return &Client{
 		scheme:         scheme,
-		dummyClient:    &dummyClient{},
+		restMapper:     newRESTMapper(scheme, frameData),
 		framesByID:     frameData,
 		effectRecorder: effectRecorder,
+		indexers:       make(map[schema.GroupKind]map[string]fieldIndexer),
 	}
 }
 
 var _ client.Client = (*Client)(nil)
+var _ client.FieldIndexer = (*Client)(nil)
 
-func inferKind(obj client.Object) string {
-	// assumption: the object is a pointer to a struct
-	t := reflect.TypeOf(obj).Elem()
-	return t.Name()
+// WithShadowState makes the writes of a replayed reconcile visible to its later reads. Writes are applied
+// to a copy of the frame's objects like the apiserver would apply them: created objects get a UID,
+// each write gets a new resourceVersion, patches are applied, and deleted objects with finalizers are
+// marked as being deleted until their last finalizer is removed. Writes that fail are not recorded as effects.
+func (c *Client) WithShadowState() *Client {
+	c.shadow = make(map[string]*shadowState)
+	return c
 }
 
-func inferListKind(list client.ObjectList) string {
-	itemsValue := reflect.ValueOf(list).Elem().FieldByName("Items")
-	if !itemsValue.IsValid() {
-		panic("List object does not have Items field")
+// frame returns the objects that the reconcile of the frame observes.
+func (c *Client) frame(frameID string) (FrameData, error) {
+	if s, ok := c.shadow[frameID]; ok {
+		return s.objects, nil
 	}
-	itemType := itemsValue.Type().Elem()
-	return itemType.Name()
+	frame, ok := c.framesByID[frameID]
+	if !ok {
+		return nil, fmt.Errorf("frame %s not found", frameID)
+	}
+	return frame, nil
+}
+
+// shadowFor returns the shadow state of the frame, which starts out with the frame's objects.
+func (c *Client) shadowFor(frameID string) (*shadowState, error) {
+	if s, ok := c.shadow[frameID]; ok {
+		return s, nil
+	}
+	frame, ok := c.framesByID[frameID]
+	if !ok {
+		return nil, fmt.Errorf("frame %s not found", frameID)
+	}
+	c.shadow[frameID] = newShadowState(frame, c.scheme)
+	return c.shadow[frameID], nil
+}
+
+// IndexField registers a field indexer like a controller-runtime cache does, so that the replayed
+// reconciler can list objects by the fields it indexed when it was set up.
+func (c *Client) IndexField(ctx context.Context, obj client.Object, field string, extractValue client.IndexerFunc) error {
+	gvk, err := c.GroupVersionKindFor(obj)
+	if err != nil {
+		return err
+	}
+	gk := gvk.GroupKind()
+	if _, ok := c.indexers[gk]; !ok {
+		c.indexers[gk] = make(map[string]fieldIndexer)
+	}
+	c.indexers[gk][field] = fieldIndexer{objType: reflect.TypeOf(obj).Elem(), extract: extractValue}
+	return nil
+}
+
+// indexerFor returns the indexer registered for the field of the given GroupKind. Like FrameData.objectsFor,
+// it falls back to matching by Kind alone if the group is not known.
+func (c *Client) indexerFor(gk schema.GroupKind, field string) (fieldIndexer, bool) {
+	if indexer, ok := c.indexers[gk][field]; ok {
+		return indexer, true
+	}
+	for k, byField := range c.indexers {
+		if k.Kind != gk.Kind || (gk.Group != "" && k.Group != "") {
+			continue
+		}
+		if indexer, ok := byField[field]; ok {
+			return indexer, true
+		}
+	}
+	return fieldIndexer{}, false
+}
+
+func (c *Client) Scheme() *runtime.Scheme {
+	return c.scheme
+}
+
+func (c *Client) RESTMapper() meta.RESTMapper {
+	return c.restMapper
+}
+
+func (c *Client) IsObjectNamespaced(obj runtime.Object) (bool, error) {
+	return apiutil.IsObjectNamespaced(obj, c.scheme, c.restMapper)
+}
+
+// GroupVersionKindFor returns the GVK of the object. Objects that carry their TypeMeta (unstructured and
+// metadata-only objects always do) are taken at their word; typed objects are resolved through the scheme.
+// The kind of a typed object that is missing from the scheme is inferred from its Go type, with an unknown group.
+func (c *Client) GroupVersionKindFor(obj runtime.Object) (schema.GroupVersionKind, error) {
+	if gvk := obj.GetObjectKind().GroupVersionKind(); gvk.Kind != "" {
+		return gvk, nil
+	}
+	if _, ok := obj.(runtime.Unstructured); ok {
+		return schema.GroupVersionKind{}, fmt.Errorf("unstructured object has no kind")
+	}
+	if _, ok := obj.(*metav1.PartialObjectMetadata); ok {
+		return schema.GroupVersionKind{}, fmt.Errorf("metadata-only object has no kind")
+	}
+	if gvk, err := apiutil.GVKForObject(obj, c.scheme); err == nil {
+		return gvk, nil
+	}
+	return util.GetGroupVersionKind(obj), nil
+}
+
+// listItemGVK returns the GVK of the items of the list.
+func (c *Client) listItemGVK(list client.ObjectList) (schema.GroupVersionKind, error) {
+	gvk, err := c.GroupVersionKindFor(list)
+	if err != nil {
+		return gvk, err
+	}
+	gvk.Kind = strings.TrimSuffix(gvk.Kind, "List")
+	return gvk, nil
+}
+
+// copyInto fills obj with the content of an object from the frame and sets its TypeMeta.
+// If gvk does not name a version, the version the object was recorded at is used.
+func copyInto(frozen *unstructured.Unstructured, obj client.Object, gvk schema.GroupVersionKind) error {
+	if gvk.Version == "" {
+		gvk = frozen.GroupVersionKind()
+	}
+	content := frozen.DeepCopy().Object
+	if u, ok := obj.(*unstructured.Unstructured); ok {
+		u.Object = content
+	} else if err := runtime.DefaultUnstructuredConverter.FromUnstructured(content, obj); err != nil {
+		return err
+	}
+	obj.GetObjectKind().SetGroupVersionKind(gvk)
+	return nil
+}
+
+// withTypeMeta returns the object with its TypeMeta set, copying it if need be, so that the effects
+// recorded for typed objects carry their kind and apiVersion.
+func (c *Client) withTypeMeta(obj client.Object) client.Object {
+	if !obj.GetObjectKind().GroupVersionKind().Empty() {
+		return obj
+	}
+	gvk, err := c.GroupVersionKindFor(obj)
+	if err != nil {
+		return obj
+	}
+	obj = obj.DeepCopyObject().(client.Object)
+	obj.GetObjectKind().SetGroupVersionKind(gvk)
+	return obj
 }
 
 func (c *Client) Get(ctx context.Context, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
 	logger = log.FromContext(ctx)
-	gvk := obj.GetObjectKind().GroupVersionKind()
 	// gvkToTypes := c.scheme.AllKnownTypes()
 	// if targetType, ok := gvkToTypes[gvk]; ok {
 	// 	// create a new object of the same type as obj
@@ -69,74 +223,220 @@ func (c *Client) Get(ctx context.Context, key client.ObjectKey, obj client.Objec
 	// }
 
 	frameID := frameIDFromContext(ctx)
-	kind := inferKind(obj)
-	logger.V(2).Info("client:requesting key %s, inferred kind: %s\n", key, kind)
-	if frame, ok := c.framesByID[frameID]; ok {
-		// DumpCacheFrameContents(frame)
-		if frozenObj, ok := frame[kind][key]; ok {
-			logger.V(2).Info("client:found object in frame")
-			c.effectRecorder.RecordEffect(ctx, frozenObj, sleeveclient.GET)
-
-			// use json.Marshal to copy the frozen object into the obj
-			data, err := json.Marshal(frozenObj)
-			if err != nil {
-				return err
-			}
-			if err := json.Unmarshal(data, obj); err != nil {
-				return err
-			}
-		} else {
-			return apierrors.NewNotFound(schema.GroupResource{Group: gvk.Group, Resource: gvk.Kind}, key.Name)
-		}
-	} else {
-		return fmt.Errorf("frame %s not found", frameID)
+	gvk, err := c.GroupVersionKindFor(obj)
+	if err != nil {
+		return err
 	}
-	return nil
+	gk := gvk.GroupKind()
+	logger.V(2).Info("client:requesting key %s, inferred kind: %s\n", key, gk)
+	frame, err := c.frame(frameID)
+	if err != nil {
+		return err
+	}
+	// DumpCacheFrameContents(frame)
+	objs, _ := frame.objectsFor(gk)
+	frozenObj, ok := objs[key]
+	if !ok {
+		c.effectRecorder.RecordAbsence(ctx, gvk, key, nil, sleeveclient.GET)
+		return apierrors.NewNotFound(groupResource(gvk), key.Name)
+	}
+	logger.V(2).Info("client:found object in frame")
+	c.effectRecorder.RecordEffect(ctx, frozenObj, sleeveclient.GET)
+	return copyInto(frozenObj, obj, gvk)
 }
 
 func (c *Client) List(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
 	frameID := frameIDFromContext(ctx)
-	kind := inferListKind(list)
-
-	if frame, ok := c.framesByID[frameID]; ok {
-		if objsForKind, ok := frame[kind]; ok {
-			// get a slice of the objects from the map values
-			objs := make([]client.Object, 0, len(objsForKind))
-			for _, obj := range objsForKind {
-				c.effectRecorder.RecordEffect(ctx, obj, sleeveclient.LIST)
-				objs = append(objs, obj)
-			}
-			// set the Items field of the list object to the slice of objects
-			itemsValue := reflect.ValueOf(list).Elem().FieldByName("Items")
-			itemsValue.Set(reflect.ValueOf(objs))
+	gvk, err := c.listItemGVK(list)
+	if err != nil {
+		return err
+	}
+	gk := gvk.GroupKind()
+	listOpts := (&client.ListOptions{}).ApplyOptions(opts)
+
+	frame, err := c.frame(frameID)
+	if err != nil {
+		return err
+	}
+	objsForKind, _ := frame.objectsFor(gk)
+	objs := make([]*unstructured.Unstructured, 0, len(objsForKind))
+	for _, obj := range objsForKind {
+		matches, err := c.matchesListOptions(gk, obj, listOpts)
+		if err != nil {
+			return err
+		}
+		if matches {
+			objs = append(objs, obj)
+		}
+	}
+	// the apiserver lists objects ordered by namespace and name
+	sort.Slice(objs, func(i, j int) bool {
+		if objs[i].GetNamespace() != objs[j].GetNamespace() {
+			return objs[i].GetNamespace() < objs[j].GetNamespace()
+		}
+		return objs[i].GetName() < objs[j].GetName()
+	})
+	if len(objs) == 0 {
+		c.effectRecorder.RecordAbsence(ctx, gvk, client.ObjectKey{Namespace: listOpts.Namespace}, listOpts, sleeveclient.LIST)
+	}
+	for _, obj := range objs {
+		c.effectRecorder.RecordEffect(ctx, obj, sleeveclient.LIST)
+	}
+	return setListItems(list, objs, gvk)
+}
+
+// matchesListOptions reports whether the object would be returned by a List with the given namespace, label selector and field selector.
+func (c *Client) matchesListOptions(gk schema.GroupKind, obj *unstructured.Unstructured, opts *client.ListOptions) (bool, error) {
+	if opts.Namespace != "" && obj.GetNamespace() != opts.Namespace {
+		return false, nil
+	}
+	if opts.LabelSelector != nil && !opts.LabelSelector.Matches(labels.Set(obj.GetLabels())) {
+		return false, nil
+	}
+	if opts.FieldSelector == nil {
+		return true, nil
+	}
+	for _, req := range opts.FieldSelector.Requirements() {
+		values, err := c.fieldValues(gk, obj, req.Field)
+		if err != nil {
+			return false, err
+		}
+		found := lo.Contains(values, req.Value)
+		if found != (req.Operator != selection.NotEquals) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// fieldValues returns the values of a field of the object for a field selector. Like the apiserver,
+// every kind can be selected by metadata.name and metadata.namespace; other fields need an indexer.
+func (c *Client) fieldValues(gk schema.GroupKind, obj *unstructured.Unstructured, field string) ([]string, error) {
+	if indexer, ok := c.indexerFor(gk, field); ok {
+		typed := reflect.New(indexer.objType).Interface().(client.Object)
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, typed); err != nil {
+			return nil, err
+		}
+		return indexer.extract(typed), nil
+	}
+	switch field {
+	case "metadata.name":
+		return []string{obj.GetName()}, nil
+	case "metadata.namespace":
+		return []string{obj.GetNamespace()}, nil
+	}
+	return nil, fmt.Errorf("no index registered for field %s of %s", field, gk)
+}
+
+// setListItems converts the objects to the list's item type and sets them as the list's items.
+// This works alike for typed, unstructured and metadata-only lists.
+func setListItems(list client.ObjectList, objs []*unstructured.Unstructured, gvk schema.GroupVersionKind) error {
+	itemsValue := reflect.ValueOf(list).Elem().FieldByName("Items")
+	if !itemsValue.IsValid() {
+		return fmt.Errorf("unable to get Items field from list")
+	}
+	itemType := itemsValue.Type().Elem()
+	items := make([]runtime.Object, 0, len(objs))
+	for _, obj := range objs {
+		item, ok := reflect.New(itemType).Interface().(client.Object)
+		if !ok {
+			return fmt.Errorf("list item type %s is not a client.Object", itemType)
+		}
+		if err := copyInto(obj, item, gvk); err != nil {
+			return err
 		}
-		return nil
+		items = append(items, item)
 	}
+	if gvk.Version != "" {
+		list.GetObjectKind().SetGroupVersionKind(gvk.GroupVersion().WithKind(gvk.Kind + "List"))
+	}
+	return meta.SetList(list, items)
+}
+
+// shadowWrite applies a write to the shadow state of the frame.
+type shadowWrite func(s *shadowState, gvk schema.GroupVersionKind, issued *unstructured.Unstructured) (*unstructured.Unstructured, error)
 
-	return fmt.Errorf("frame %s not found", frameID)
+// write records a write of the reconciler. With shadow state, the write is first applied, and obj is
+// updated with the resulting object like it would be by the apiserver.
+func (c *Client) write(ctx context.Context, obj client.Object, op sleeveclient.OperationType, apply shadowWrite) error {
+	issued, err := c.applyShadow(ctx, obj, apply)
+	if err != nil {
+		return err
+	}
+	return c.effectRecorder.RecordEffect(ctx, issued, op)
+}
+
+// applyShadow applies a write to the shadow state of the frame, if shadow state is enabled.
+// It returns obj as the reconciler issued it, for its effect to be recorded.
+func (c *Client) applyShadow(ctx context.Context, obj client.Object, apply shadowWrite) (client.Object, error) {
+	issued := c.withTypeMeta(obj)
+	if c.shadow == nil || apply == nil {
+		return issued, nil
+	}
+	issued = issued.DeepCopyObject().(client.Object)
+	gvk, err := c.GroupVersionKindFor(issued)
+	if err != nil {
+		return nil, err
+	}
+	s, err := c.shadowFor(frameIDFromContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	u, err := toUnstructured(issued)
+	if err != nil {
+		return nil, err
+	}
+	result, err := apply(s, gvk, u)
+	if err != nil {
+		return nil, err
+	}
+	if result != nil {
+		if err := copyInto(result, obj, gvk); err != nil {
+			return nil, err
+		}
+	}
+	return issued, nil
 }
 
 func (c *Client) Create(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
-	c.effectRecorder.RecordEffect(ctx, obj, sleeveclient.CREATE)
-	return nil
+	return c.write(ctx, obj, sleeveclient.CREATE, func(s *shadowState, gvk schema.GroupVersionKind, issued *unstructured.Unstructured) (*unstructured.Unstructured, error) {
+		return s.create(gvk, issued)
+	})
 }
 
 func (c *Client) Delete(ctx context.Context, obj client.Object, opts ...client.DeleteOption) error {
-	c.effectRecorder.RecordEffect(ctx, obj, sleeveclient.DELETE)
-	return nil
+	return c.write(ctx, obj, sleeveclient.DELETE, func(s *shadowState, gvk schema.GroupVersionKind, issued *unstructured.Unstructured) (*unstructured.Unstructured, error) {
+		return nil, s.delete(gvk, client.ObjectKeyFromObject(issued))
+	})
 }
 
 func (c *Client) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
-	c.effectRecorder.RecordEffect(ctx, obj, sleeveclient.UPDATE)
-	return nil
+	return c.write(ctx, obj, sleeveclient.UPDATE, func(s *shadowState, gvk schema.GroupVersionKind, issued *unstructured.Unstructured) (*unstructured.Unstructured, error) {
+		return s.update(gvk, issued)
+	})
 }
 
 func (c *Client) DeleteAllOf(ctx context.Context, obj client.Object, opts ...client.DeleteAllOfOption) error {
-	c.effectRecorder.RecordEffect(ctx, obj, sleeveclient.DELETE)
-	return nil
+	deleteOpts := (&client.DeleteAllOfOptions{}).ApplyOptions(opts)
+	return c.write(ctx, obj, sleeveclient.DELETE, func(s *shadowState, gvk schema.GroupVersionKind, _ *unstructured.Unstructured) (*unstructured.Unstructured, error) {
+		for key, existing := range s.objectsFor(gvk.GroupKind()) {
+			matches, err := c.matchesListOptions(gvk.GroupKind(), existing, &deleteOpts.ListOptions)
+			if err != nil {
+				return nil, err
+			}
+			if !matches {
+				continue
+			}
+			if err := s.delete(gvk, key); err != nil {
+				return nil, err
+			}
+		}
+		return nil, nil
+	})
 }
 
 func (c *Client) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
-	c.effectRecorder.RecordEffect(ctx, obj, sleeveclient.PATCH)
-	return nil
+	return c.write(ctx, obj, sleeveclient.PATCH, func(s *shadowState, gvk schema.GroupVersionKind, _ *unstructured.Unstructured) (*unstructured.Unstructured, error) {
+		return s.patch(gvk, obj, patch)
+	})
 }
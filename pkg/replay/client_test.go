@@ -0,0 +1,315 @@
+package replay
+
+import (
+	"context"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+func TestReplayListSelectsAndOrders(t *testing.T) {
+	pod := func(namespace, name, node, app string) *unstructured.Unstructured {
+		u := &unstructured.Unstructured{}
+		u.SetAPIVersion("v1")
+		u.SetKind("Pod")
+		u.SetNamespace(namespace)
+		u.SetName(name)
+		u.SetLabels(map[string]string{"app": app})
+		unstructured.SetNestedField(u.Object, node, "spec", "nodeName")
+		return u
+	}
+	pods := make(map[types.NamespacedName]*unstructured.Unstructured)
+	for _, p := range []*unstructured.Unstructured{
+		pod("default", "web-c", "node-1", "web"),
+		pod("default", "web-a", "node-1", "web"),
+		pod("default", "web-b", "node-2", "web"),
+		pod("default", "db", "node-1", "db"),
+		pod("other", "web", "node-1", "web"),
+	} {
+		pods[types.NamespacedName{Namespace: p.GetNamespace(), Name: p.GetName()}] = p
+	}
+	frames := map[string]FrameData{"frame-1": {schema.GroupKind{Kind: "Pod"}: pods}}
+	recorder := &Recorder{reconcilerID: "test-controller", effectContainer: make(map[string]DataEffect)}
+	c := NewClient(nil, frames, recorder)
+	ctx := WithFrameID(context.Background(), "frame-1")
+
+	if err := c.IndexField(ctx, &corev1.Pod{}, "spec.nodeName", func(obj client.Object) []string {
+		return []string{obj.(*corev1.Pod).Spec.NodeName}
+	}); err != nil {
+		t.Fatalf("failed to register indexer: %v", err)
+	}
+
+	names := func(list *corev1.PodList) []string {
+		out := make([]string, 0, len(list.Items))
+		for _, p := range list.Items {
+			out = append(out, p.Namespace+"/"+p.Name)
+		}
+		return out
+	}
+	tests := []struct {
+		name string
+		opts []client.ListOption
+		want []string
+	}{
+		{name: "all, in order", want: []string{"default/db", "default/web-a", "default/web-b", "default/web-c", "other/web"}},
+		{name: "namespace", opts: []client.ListOption{client.InNamespace("other")}, want: []string{"other/web"}},
+		{
+			name: "label selector",
+			opts: []client.ListOption{client.InNamespace("default"), client.MatchingLabelsSelector{Selector: labels.SelectorFromSet(labels.Set{"app": "web"})}},
+			want: []string{"default/web-a", "default/web-b", "default/web-c"},
+		},
+		{
+			name: "indexed field",
+			opts: []client.ListOption{client.InNamespace("default"), client.MatchingLabels{"app": "web"}, client.MatchingFields{"spec.nodeName": "node-1"}},
+			want: []string{"default/web-a", "default/web-c"},
+		},
+		{name: "metadata field", opts: []client.ListOption{client.MatchingFields{"metadata.name": "web"}}, want: []string{"other/web"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			list := &corev1.PodList{}
+			if err := c.List(ctx, list, tt.opts...); err != nil {
+				t.Fatalf("list failed: %v", err)
+			}
+			got := names(list)
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("got %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+
+	if err := c.List(ctx, &corev1.PodList{}, client.MatchingFields{"status.phase": "Running"}); err == nil {
+		t.Errorf("expected listing by an unindexed field to fail")
+	}
+}
+
+func TestReplayClientTypeHandling(t *testing.T) {
+	cm := &unstructured.Unstructured{}
+	cm.SetAPIVersion("v1")
+	cm.SetKind("ConfigMap")
+	cm.SetNamespace("default")
+	cm.SetName("config")
+	unstructured.SetNestedField(cm.Object, "1", "data", "scale")
+	deploy := &unstructured.Unstructured{}
+	deploy.SetAPIVersion("apps/v1")
+	deploy.SetKind("Deployment")
+	deploy.SetNamespace("default")
+	deploy.SetName("web")
+	frames := map[string]FrameData{"frame-1": {
+		schema.GroupKind{Kind: "ConfigMap"}:                 {{Namespace: "default", Name: "config"}: cm},
+		schema.GroupKind{Group: "apps", Kind: "Deployment"}: {{Namespace: "default", Name: "web"}: deploy},
+	}}
+	recorder := &Recorder{reconcilerID: "test-controller", effectContainer: make(map[string]DataEffect)}
+	c := NewClient(clientgoscheme.Scheme, frames, recorder)
+	ctx := WithFrameID(context.Background(), "frame-1")
+	key := types.NamespacedName{Namespace: "default", Name: "web"}
+	deployGVK := schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}
+
+	typed := &corev1.ConfigMap{}
+	if err := c.Get(ctx, types.NamespacedName{Namespace: "default", Name: "config"}, typed); err != nil {
+		t.Fatalf("typed get failed: %v", err)
+	}
+	if typed.Data["scale"] != "1" || typed.APIVersion != "v1" || typed.Kind != "ConfigMap" {
+		t.Errorf("unexpected typed object: %+v", typed)
+	}
+
+	u := &unstructured.Unstructured{}
+	u.SetGroupVersionKind(deployGVK)
+	if err := c.Get(ctx, key, u); err != nil {
+		t.Fatalf("unstructured get failed: %v", err)
+	}
+	if u.GetName() != "web" || u.GroupVersionKind() != deployGVK {
+		t.Errorf("unexpected unstructured object: %v", u.Object)
+	}
+
+	partial := &metav1.PartialObjectMetadata{}
+	partial.SetGroupVersionKind(deployGVK)
+	if err := c.Get(ctx, key, partial); err != nil {
+		t.Fatalf("metadata-only get failed: %v", err)
+	}
+	if partial.Name != "web" || partial.GroupVersionKind() != deployGVK {
+		t.Errorf("unexpected metadata-only object: %+v", partial)
+	}
+
+	ul := &unstructured.UnstructuredList{}
+	ul.SetGroupVersionKind(deployGVK.GroupVersion().WithKind("DeploymentList"))
+	if err := c.List(ctx, ul); err != nil {
+		t.Fatalf("unstructured list failed: %v", err)
+	}
+	if len(ul.Items) != 1 || ul.Items[0].GroupVersionKind() != deployGVK {
+		t.Errorf("unexpected unstructured list: %+v", ul.Items)
+	}
+
+	pl := &metav1.PartialObjectMetadataList{}
+	pl.SetGroupVersionKind(deployGVK.GroupVersion().WithKind("DeploymentList"))
+	if err := c.List(ctx, pl); err != nil {
+		t.Fatalf("metadata-only list failed: %v", err)
+	}
+	if len(pl.Items) != 1 || pl.Items[0].Name != "web" || pl.Items[0].GroupVersionKind() != deployGVK {
+		t.Errorf("unexpected metadata-only list: %+v", pl.Items)
+	}
+
+	if err := c.Create(ctx, &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "api"}}); err != nil {
+		t.Fatalf("create failed: %v", err)
+	}
+	writes := recorder.effectContainer["frame-1"].Writes
+	if len(writes) != 1 || writes[0].Kind != "Deployment" || writes[0].APIVersion != "apps/v1" {
+		t.Errorf("expected the typed create to be recorded as an apps/v1 Deployment, got %+v", writes)
+	}
+}
+
+func TestReplayClientMappingAndSubResources(t *testing.T) {
+	owner := &unstructured.Unstructured{}
+	owner.SetAPIVersion("apps/v1")
+	owner.SetKind("Deployment")
+	owner.SetNamespace("default")
+	owner.SetName("web")
+	owner.SetUID("owner-uid")
+	frames := map[string]FrameData{"frame-1": {
+		schema.GroupKind{Group: "apps", Kind: "Deployment"}: {{Namespace: "default", Name: "web"}: owner},
+	}}
+	recorder := &Recorder{reconcilerID: "test-controller", effectContainer: make(map[string]DataEffect)}
+	c := NewClient(nil, frames, recorder)
+	ctx := WithFrameID(context.Background(), "frame-1")
+
+	mapping, err := c.RESTMapper().RESTMapping(schema.GroupKind{Group: "apps", Kind: "Deployment"}, "v1")
+	if err != nil {
+		t.Fatalf("failed to map Deployment: %v", err)
+	}
+	if mapping.Resource.Resource != "deployments" {
+		t.Errorf("expected Deployment to map to deployments, got %s", mapping.Resource)
+	}
+	if namespaced, err := c.IsObjectNamespaced(&corev1.ConfigMap{}); err != nil || !namespaced {
+		t.Errorf("expected ConfigMap to be namespaced, got %v (err: %v)", namespaced, err)
+	}
+	if namespaced, err := c.IsObjectNamespaced(&corev1.Namespace{}); err != nil || namespaced {
+		t.Errorf("expected Namespace to be cluster-scoped, got %v (err: %v)", namespaced, err)
+	}
+
+	deploy := &appsv1.Deployment{}
+	if err := c.Get(ctx, types.NamespacedName{Namespace: "default", Name: "web"}, deploy); err != nil {
+		t.Fatalf("get failed: %v", err)
+	}
+	rs := &appsv1.ReplicaSet{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web-1"}}
+	if err := controllerutil.SetControllerReference(deploy, rs, c.Scheme()); err != nil {
+		t.Fatalf("failed to set controller reference: %v", err)
+	}
+	if ref := metav1.GetControllerOf(rs); ref == nil || ref.Kind != "Deployment" || ref.APIVersion != "apps/v1" {
+		t.Errorf("unexpected controller reference: %+v", ref)
+	}
+
+	deploy.Status.ReadyReplicas = 1
+	if err := c.Status().Update(ctx, deploy); err != nil {
+		t.Fatalf("status update failed: %v", err)
+	}
+	if err := c.SubResource("status").Get(ctx, deploy, &appsv1.Deployment{}); err == nil {
+		t.Errorf("expected reading a subresource to fail")
+	}
+	writes := recorder.effectContainer["frame-1"].Writes
+	if len(writes) != 1 || writes[0].OpType != "UPDATE" || writes[0].SubResource != "status" || writes[0].Kind != "Deployment" {
+		t.Errorf("expected a status update of the Deployment to be recorded, got %+v", writes)
+	}
+}
+
+func TestReplayShadowState(t *testing.T) {
+	cm := &unstructured.Unstructured{}
+	cm.SetAPIVersion("v1")
+	cm.SetKind("ConfigMap")
+	cm.SetNamespace("default")
+	cm.SetName("config")
+	cm.SetUID("config-uid")
+	cm.SetResourceVersion("5")
+	deploy := &unstructured.Unstructured{}
+	deploy.SetAPIVersion("apps/v1")
+	deploy.SetKind("Deployment")
+	deploy.SetNamespace("default")
+	deploy.SetName("web")
+	deploy.SetResourceVersion("3")
+	deploy.SetFinalizers([]string{"example.com/cleanup"})
+	frames := map[string]FrameData{"frame-1": {
+		schema.GroupKind{Kind: "ConfigMap"}:                 {{Namespace: "default", Name: "config"}: cm},
+		schema.GroupKind{Group: "apps", Kind: "Deployment"}: {{Namespace: "default", Name: "web"}: deploy},
+	}}
+	recorder := &Recorder{reconcilerID: "test-controller", effectContainer: make(map[string]DataEffect)}
+	c := NewClient(nil, frames, recorder).WithShadowState()
+	ctx := WithFrameID(context.Background(), "frame-1")
+	configKey := types.NamespacedName{Namespace: "default", Name: "config"}
+	webKey := types.NamespacedName{Namespace: "default", Name: "web"}
+
+	created := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "default", GenerateName: "new-"}}
+	if err := c.Create(ctx, created); err != nil {
+		t.Fatalf("create failed: %v", err)
+	}
+	if created.Name == "" || created.UID == "" || created.ResourceVersion != "6" {
+		t.Errorf("expected the created object to be named and versioned, got %+v", created.ObjectMeta)
+	}
+	got := &corev1.ConfigMap{}
+	if err := c.Get(ctx, client.ObjectKeyFromObject(created), got); err != nil {
+		t.Fatalf("expected the created object to be readable: %v", err)
+	}
+
+	config := &corev1.ConfigMap{}
+	if err := c.Get(ctx, configKey, config); err != nil {
+		t.Fatalf("get failed: %v", err)
+	}
+	stale := config.DeepCopy()
+	config.Data = map[string]string{"scale": "2"}
+	if err := c.Update(ctx, config); err != nil {
+		t.Fatalf("update failed: %v", err)
+	}
+	if err := c.Update(ctx, stale); !apierrors.IsConflict(err) {
+		t.Errorf("expected an update at a stale resourceVersion to conflict, got %v", err)
+	}
+	base := config.DeepCopy()
+	config.Data["mode"] = "fast"
+	if err := c.Patch(ctx, config, client.MergeFrom(base)); err != nil {
+		t.Fatalf("patch failed: %v", err)
+	}
+	got = &corev1.ConfigMap{}
+	if err := c.Get(ctx, configKey, got); err != nil {
+		t.Fatalf("get failed: %v", err)
+	}
+	if got.Data["scale"] != "2" || got.Data["mode"] != "fast" || got.UID != "config-uid" || got.ResourceVersion != "8" {
+		t.Errorf("expected the update and patch to be applied, got %+v", got)
+	}
+
+	if err := c.Delete(ctx, &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web"}}); err != nil {
+		t.Fatalf("delete failed: %v", err)
+	}
+	web := &appsv1.Deployment{}
+	if err := c.Get(ctx, webKey, web); err != nil {
+		t.Fatalf("expected an object with finalizers to outlive its deletion: %v", err)
+	}
+	if web.DeletionTimestamp == nil {
+		t.Errorf("expected the object to be marked as being deleted")
+	}
+	web.Finalizers = nil
+	if err := c.Update(ctx, web); err != nil {
+		t.Fatalf("update failed: %v", err)
+	}
+	if err := c.Get(ctx, webKey, &appsv1.Deployment{}); !apierrors.IsNotFound(err) {
+		t.Errorf("expected the object to be gone once its finalizers were removed, got %v", err)
+	}
+
+	if writes := recorder.effectContainer["frame-1"].Writes; len(writes) != 5 {
+		t.Errorf("expected the 5 successful writes to be recorded, got %d", len(writes))
+	}
+	if _, ok := frames["frame-1"][schema.GroupKind{Kind: "ConfigMap"}][client.ObjectKeyFromObject(created)]; ok {
+		t.Errorf("expected the frame itself to be left unchanged")
+	}
+}
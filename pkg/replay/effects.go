@@ -6,12 +6,25 @@ import (
 
 	sleeveclient "github.com/tgoodwin/sleeve/pkg/client"
 	"github.com/tgoodwin/sleeve/pkg/event"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
 type DataEffect struct {
 	Reads  []event.Event
 	Writes []event.Event
+
+	// the objects as they were written, keyed by the index of their write in Writes.
+	// A traced write only has an object if the trace holds the version of the object that it produced.
+	Written map[int]*unstructured.Unstructured
+}
+
+// written returns the object of the i-th write, if it is known.
+func (de DataEffect) written(i int) (*unstructured.Unstructured, bool) {
+	obj, ok := de.Written[i]
+	return obj, ok
 }
 
 type EffectHandler interface {
@@ -42,8 +55,17 @@ func (r *Recorder) Retrieve(frameID string) (DataEffect, bool) {
 var _ EffectHandler = (*Recorder)(nil)
 
 func (r *Recorder) RecordEffect(ctx context.Context, obj client.Object, opType sleeveclient.OperationType) error {
+	return r.recordEffect(ctx, obj, "", opType)
+}
+
+func (r *Recorder) RecordSubResourceEffect(ctx context.Context, obj client.Object, subResource string, opType sleeveclient.OperationType) error {
+	return r.recordEffect(ctx, obj, subResource, opType)
+}
+
+func (r *Recorder) recordEffect(ctx context.Context, obj client.Object, subResource string, opType sleeveclient.OperationType) error {
 	reconcileID := frameIDFromContext(ctx)
 	e := sleeveclient.Operation(obj, reconcileID, r.reconcilerID, "<REPLAY>", opType)
+	e.SubResource = subResource
 
 	de, exists := r.effectContainer[reconcileID]
 	if !exists {
@@ -54,6 +76,12 @@ func (r *Recorder) RecordEffect(ctx context.Context, obj client.Object, opType s
 		de.Reads = append(de.Reads, *e)
 	} else if event.IsWriteOp(*e) {
 		de.Writes = append(de.Writes, *e)
+		if written, err := toUnstructured(obj); err == nil {
+			if de.Written == nil {
+				de.Written = make(map[int]*unstructured.Unstructured)
+			}
+			de.Written[len(de.Writes)-1] = written
+		}
 		// in the case where we are recording a perturbed execution,
 		// see if the perturbation produced the desired effect
 		r.evaluatePredicates(ctx, obj)
@@ -63,9 +91,30 @@ func (r *Recorder) RecordEffect(ctx context.Context, obj client.Object, opType s
 	return nil
 }
 
+func (r *Recorder) RecordAbsence(ctx context.Context, gvk schema.GroupVersionKind, key client.ObjectKey, listOpts *client.ListOptions, opType sleeveclient.OperationType) error {
+	reconcileID := frameIDFromContext(ctx)
+	e := sleeveclient.Absence(gvk, key, listOpts, reconcileID, r.reconcilerID, "<REPLAY>", opType)
+
+	de := r.effectContainer[reconcileID]
+	de.Reads = append(de.Reads, *e)
+	r.effectContainer[reconcileID] = de
+	return nil
+}
+
 func (r *Recorder) evaluatePredicates(_ context.Context, obj client.Object) {
+	if len(r.predicates) == 0 {
+		return
+	}
+	u, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+		if err != nil {
+			return
+		}
+		u = &unstructured.Unstructured{Object: content}
+	}
 	for _, p := range r.predicates {
-		if p.evaluate(obj) {
+		if p.evaluate(u) {
 			p.satisfied = true
 		}
 	}
@@ -1,10 +1,14 @@
 package replay
 
 import (
+	"cmp"
 	"context"
 	"fmt"
+	"strconv"
+	"strings"
 
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 )
@@ -21,14 +25,48 @@ type Frame struct {
 	ID   string
 	Type FrameType
 
-	// for ordering. In practice this is just a timestamp
-	sequenceID string
+	// for ordering
+	sequence sequence
 
 	Req reconcile.Request
 
 	TraceyRootID string
 }
 
+// sequence is the position of a frame in the trace. Frames are ordered by the logical clock of the
+// reconcile's first operation, which unlike wall-clock time is consistent with causality across pods.
+// The timestamp breaks ties and orders frames from traces that predate logical clocks. Such frames
+// cannot be placed among clocked frames, so they follow them.
+type sequence struct {
+	clock     uint64
+	timestamp string
+}
+
+func (s sequence) before(other sequence) bool {
+	if (s.clock == 0) != (other.clock == 0) {
+		return other.clock == 0
+	}
+	if s.clock != other.clock {
+		return s.clock < other.clock
+	}
+	return compareTimestamps(s.timestamp, other.timestamp) < 0
+}
+
+func (s sequence) String() string {
+	return fmt.Sprintf("clock %d (ts %s)", s.clock, s.timestamp)
+}
+
+// compareTimestamps compares timestamps formatted by event.FormatTimeStr numerically,
+// falling back to a string comparison for timestamps in other formats.
+func compareTimestamps(a, b string) int {
+	ai, errA := strconv.ParseInt(a, 10, 64)
+	bi, errB := strconv.ParseInt(b, 10, 64)
+	if errA != nil || errB != nil {
+		return strings.Compare(a, b)
+	}
+	return cmp.Compare(ai, bi)
+}
+
 type frameIDKey struct{}
 
 func WithFrameID(ctx context.Context, id string) context.Context {
@@ -43,7 +81,27 @@ func frameIDFromContext(ctx context.Context) string {
 	return id
 }
 
-type FrameData map[string]map[types.NamespacedName]*unstructured.Unstructured
+// FrameData holds the objects that a reconcile observed, keyed by GroupKind and name.
+type FrameData map[schema.GroupKind]map[types.NamespacedName]*unstructured.Unstructured
+
+// objectsFor returns the objects of the given GroupKind. Objects whose group could not be
+// determined are matched by Kind alone, provided only one group has objects of that Kind.
+func (c FrameData) objectsFor(gk schema.GroupKind) (map[types.NamespacedName]*unstructured.Unstructured, bool) {
+	if objs, ok := c[gk]; ok || gk.Group != "" {
+		return objs, ok
+	}
+	var found map[types.NamespacedName]*unstructured.Unstructured
+	for k, objs := range c {
+		if k.Kind != gk.Kind {
+			continue
+		}
+		if found != nil {
+			return nil, false
+		}
+		found = objs
+	}
+	return found, found != nil
+}
 
 func (c FrameData) Copy() FrameData {
 	newFrame := make(FrameData)
@@ -59,7 +117,7 @@ func (c FrameData) Copy() FrameData {
 func (c FrameData) Dump() {
 	for kind, objs := range c {
 		for nn := range objs {
-			fmt.Printf("\t%s/%s/%s\n", kind, nn.Namespace, nn.Name)
+			fmt.Printf("\t%s/%s/%s\n", kind.String(), nn.Namespace, nn.Name)
 		}
 	}
 }
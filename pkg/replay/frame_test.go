@@ -0,0 +1,61 @@
+package replay
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestFrameDataObjectsFor(t *testing.T) {
+	capi := schema.GroupKind{Group: "cluster.x-k8s.io", Kind: "Cluster"}
+	other := schema.GroupKind{Group: "example.com", Kind: "Cluster"}
+	secret := schema.GroupKind{Kind: "Secret"}
+	deploy := schema.GroupKind{Group: "apps", Kind: "Deployment"}
+	objs := func() map[types.NamespacedName]*unstructured.Unstructured {
+		return map[types.NamespacedName]*unstructured.Unstructured{{Name: "x"}: {}}
+	}
+	frame := FrameData{capi: objs(), other: objs(), secret: objs(), deploy: objs()}
+
+	tests := []struct {
+		name string
+		gk   schema.GroupKind
+		want bool
+	}{
+		{name: "exact", gk: capi, want: true},
+		{name: "core", gk: secret, want: true},
+		{name: "kind only", gk: schema.GroupKind{Kind: "Deployment"}, want: true},
+		{name: "ambiguous kind only", gk: schema.GroupKind{Kind: "Cluster"}, want: false},
+		{name: "wrong group", gk: schema.GroupKind{Group: "extensions", Kind: "Deployment"}, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, ok := frame.objectsFor(tt.gk); ok != tt.want {
+				t.Errorf("expected found=%v for %s", tt.want, tt.gk)
+			}
+		})
+	}
+}
+
+func TestSequenceOrdering(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b sequence
+		want bool
+	}{
+		{name: "clock within the same millisecond", a: sequence{clock: 3, timestamp: "1000"}, b: sequence{clock: 4, timestamp: "1000"}, want: true},
+		{name: "clock over skewed timestamps", a: sequence{clock: 3, timestamp: "2000"}, b: sequence{clock: 4, timestamp: "1000"}, want: true},
+		{name: "timestamp breaks clock ties", a: sequence{clock: 3, timestamp: "2000"}, b: sequence{clock: 3, timestamp: "1000"}, want: false},
+		{name: "timestamps without clocks", a: sequence{timestamp: "999"}, b: sequence{timestamp: "1000"}, want: true},
+		{name: "clocked before unclocked", a: sequence{clock: 9, timestamp: "2000"}, b: sequence{timestamp: "1000"}, want: true},
+		{name: "unclocked after clocked", a: sequence{timestamp: "1000"}, b: sequence{clock: 9, timestamp: "2000"}, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.a.before(tt.b); got != tt.want {
+				t.Errorf("%s before %s = %v, want %v", tt.a, tt.b, got, tt.want)
+			}
+		})
+	}
+}
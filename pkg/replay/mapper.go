@@ -0,0 +1,82 @@
+package replay
+
+import (
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// clusterScoped are the built-in kinds whose objects do not belong to a namespace.
+var clusterScoped = map[schema.GroupKind]struct{}{
+	{Kind: "Namespace"}:        {},
+	{Kind: "Node"}:             {},
+	{Kind: "PersistentVolume"}: {},
+	{Kind: "ComponentStatus"}:  {},
+	{Group: "rbac.authorization.k8s.io", Kind: "ClusterRole"}:                       {},
+	{Group: "rbac.authorization.k8s.io", Kind: "ClusterRoleBinding"}:                {},
+	{Group: "storage.k8s.io", Kind: "StorageClass"}:                                 {},
+	{Group: "storage.k8s.io", Kind: "CSIDriver"}:                                    {},
+	{Group: "storage.k8s.io", Kind: "CSINode"}:                                      {},
+	{Group: "storage.k8s.io", Kind: "VolumeAttachment"}:                             {},
+	{Group: "scheduling.k8s.io", Kind: "PriorityClass"}:                             {},
+	{Group: "node.k8s.io", Kind: "RuntimeClass"}:                                    {},
+	{Group: "networking.k8s.io", Kind: "IngressClass"}:                              {},
+	{Group: "certificates.k8s.io", Kind: "CertificateSigningRequest"}:               {},
+	{Group: "admissionregistration.k8s.io", Kind: "MutatingWebhookConfiguration"}:   {},
+	{Group: "admissionregistration.k8s.io", Kind: "ValidatingWebhookConfiguration"}: {},
+	{Group: "apiextensions.k8s.io", Kind: "CustomResourceDefinition"}:               {},
+	{Group: "apiregistration.k8s.io", Kind: "APIService"}:                           {},
+	{Group: "flowcontrol.apiserver.k8s.io", Kind: "FlowSchema"}:                     {},
+	{Group: "flowcontrol.apiserver.k8s.io", Kind: "PriorityLevelConfiguration"}:     {},
+}
+
+// nonResourceKinds are the kinds that metav1 registers in every group version for API machinery,
+// which no resource serves.
+var nonResourceKinds = map[string]struct{}{
+	metav1.WatchEventKind: {},
+	"ListOptions":         {},
+	"GetOptions":          {},
+	"DeleteOptions":       {},
+	"CreateOptions":       {},
+	"UpdateOptions":       {},
+	"PatchOptions":        {},
+	"Status":              {},
+	"APIVersions":         {},
+	"APIGroupList":        {},
+	"APIGroup":            {},
+	"APIResourceList":     {},
+}
+
+// newRESTMapper maps the kinds of the scheme to resources without an apiserver to discover them from.
+// Resource names are guessed from the kind, and kinds are namespaced unless they are built-in cluster-scoped
+// kinds or the trace only holds objects of the kind that have no namespace.
+func newRESTMapper(scheme *runtime.Scheme, frames map[string]FrameData) meta.RESTMapper {
+	clusterScopedInTrace := make(map[schema.GroupKind]bool)
+	for _, frame := range frames {
+		for gk, objs := range frame {
+			for _, obj := range objs {
+				scoped, seen := clusterScopedInTrace[gk]
+				clusterScopedInTrace[gk] = obj.GetNamespace() == "" && (scoped || !seen)
+			}
+		}
+	}
+
+	mapper := meta.NewDefaultRESTMapper(scheme.PrioritizedVersionsAllGroups())
+	for gvk := range scheme.AllKnownTypes() {
+		if gvk.Version == runtime.APIVersionInternal || strings.HasSuffix(gvk.Kind, "List") {
+			continue
+		}
+		if _, ok := nonResourceKinds[gvk.Kind]; ok {
+			continue
+		}
+		scope := meta.RESTScopeNamespace
+		if _, ok := clusterScoped[gvk.GroupKind()]; ok || clusterScopedInTrace[gvk.GroupKind()] {
+			scope = meta.RESTScopeRoot
+		}
+		mapper.Add(gvk, scope)
+	}
+	return mapper
+}
@@ -0,0 +1,61 @@
+package replay
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+)
+
+func TestRESTMapperScopes(t *testing.T) {
+	scheme := clientgoscheme.Scheme
+	mapper := newRESTMapper(scheme, nil)
+
+	for gk := range clusterScoped {
+		versions := scheme.VersionsForGroupKind(gk)
+		if len(versions) == 0 {
+			// not a client-go kind, e.g. CustomResourceDefinition
+			continue
+		}
+		mapping, err := mapper.RESTMapping(gk, versions[0].Version)
+		if err != nil {
+			t.Errorf("no mapping for %s: %v", gk, err)
+			continue
+		}
+		if mapping.Scope.Name() != meta.RESTScopeNameRoot {
+			t.Errorf("expected %s to be cluster-scoped", gk)
+		}
+	}
+
+	for _, gk := range []schema.GroupKind{{Kind: "ConfigMap"}, {Group: "apps", Kind: "Deployment"}, {Group: "rbac.authorization.k8s.io", Kind: "Role"}} {
+		mapping, err := mapper.RESTMapping(gk)
+		if err != nil {
+			t.Errorf("no mapping for %s: %v", gk, err)
+			continue
+		}
+		if mapping.Scope.Name() != meta.RESTScopeNameNamespace {
+			t.Errorf("expected %s to be namespaced", gk)
+		}
+	}
+}
+
+func TestRESTMapperSkipsNonResourceKinds(t *testing.T) {
+	mapper := newRESTMapper(clientgoscheme.Scheme, nil)
+	for _, gvk := range []schema.GroupVersionKind{
+		{Group: "apps", Version: "v1", Kind: "ListOptions"},
+		{Group: "apps", Version: "v1", Kind: "GetOptions"},
+		{Group: "apps", Version: "v1", Kind: "DeleteOptions"},
+		{Group: "apps", Version: "v1", Kind: "WatchEvent"},
+		{Group: "apps", Version: "v1", Kind: "DeploymentList"},
+		{Version: "v1", Kind: "Status"},
+	} {
+		plural, _ := meta.UnsafeGuessKindToResource(gvk)
+		if served, err := mapper.KindFor(plural); err == nil {
+			t.Errorf("expected no kind to serve %s, got %s", plural, served)
+		}
+	}
+	if _, err := mapper.KindFor(schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}); err != nil {
+		t.Errorf("expected configmaps to be mapped: %v", err)
+	}
+}
@@ -1,60 +1,51 @@
 package replay
 
 import (
+	"errors"
+	"io"
 	"strings"
 
-	"github.com/pkg/errors"
-	"github.com/samber/lo"
 	"github.com/tgoodwin/sleeve/pkg/event"
 	"github.com/tgoodwin/sleeve/pkg/snapshot"
 	"github.com/tgoodwin/sleeve/pkg/tag"
+	"github.com/tgoodwin/sleeve/pkg/trace"
 )
 
-func filterSleeveLines(lines []string) []string {
-	return lo.FilterMap(lines, func(l string, _ int) (string, bool) {
-		parts := strings.SplitN(l, tag.LoggerName, 2)
-		isSleeveLine := strings.Contains(l, tag.LoggerName)
-		if len(parts) > 1 {
-			l = strings.TrimSpace(parts[1])
+// readRecords decodes the records of the given log type from the lines of a trace.
+// Records of other types that cannot be decoded are skipped.
+func readRecords[T any](lines []string, logType string, value func(*trace.Record) *T) ([]T, error) {
+	reader := trace.NewReader(strings.NewReader(strings.Join(lines, "\n")))
+	records := make([]T, 0)
+	for {
+		rec, err := reader.Next()
+		if err == io.EOF {
+			return records, nil
+		}
+		var decodeErr *trace.DecodeError
+		if errors.As(err, &decodeErr) && decodeErr.LogType != logType {
+			continue
 		}
-		return l, isSleeveLine
-	})
-}
-
-func ParseRecordsFromLines(lines []string) ([]snapshot.Record, error) {
-	sleeveLines := filterSleeveLines(lines)
-	versionLines := lo.FilterMap(sleeveLines, func(l string, _ int) (string, bool) {
-		return tag.StripLogKey(l), strings.Contains(l, tag.ObjectVersionKey)
-	})
-	var loadErr error
-	records := lo.Map(versionLines, func(v string, _ int) snapshot.Record {
-		r, err := snapshot.LoadFromString(v)
 		if err != nil {
-			loadErr = errors.Wrap(err, "failed to load record from string")
+			return nil, err
+		}
+		if rec.LogType == logType {
+			records = append(records, *value(rec))
 		}
-		return r
-	})
-	if loadErr != nil {
-		return nil, loadErr
 	}
-	return records, nil
+}
+
+func ParseRecordsFromLines(lines []string) ([]snapshot.Record, error) {
+	return readRecords(lines, tag.ObjectVersionKey, func(r *trace.Record) *snapshot.Record { return r.Snapshot })
 }
 
 func ParseEventsFromLines(lines []string) ([]event.Event, error) {
-	sleeveLines := filterSleeveLines(lines)
-	eventLines := lo.FilterMap(sleeveLines, func(l string, _ int) (string, bool) {
-		return tag.StripLogKey(l), strings.Contains(l, tag.ControllerOperationKey)
-	})
-	var loadErr error
-	events := lo.Map(eventLines, func(l string, _ int) event.Event {
-		var e event.Event
-		if err := e.UnmarshalJSON([]byte(l)); err != nil {
-			loadErr = errors.Wrap(err, "failed to unmarshal event from json")
-		}
-		return e
-	})
-	if loadErr != nil {
-		return nil, loadErr
-	}
-	return events, nil
+	return readRecords(lines, tag.ControllerOperationKey, func(r *trace.Record) *event.Event { return r.Event })
+}
+
+func ParseReconcilesFromLines(lines []string) ([]event.Reconcile, error) {
+	return readRecords(lines, tag.ReconcileKey, func(r *trace.Record) *event.Reconcile { return r.Reconcile })
+}
+
+func ParseListObservationsFromLines(lines []string) ([]event.ListObservation, error) {
+	return readRecords(lines, tag.ListObservationKey, func(r *trace.Record) *event.ListObservation { return r.ListObservation })
 }
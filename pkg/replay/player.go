@@ -22,6 +22,9 @@ type ReplayHarness struct {
 	replayEffects map[string]DataEffect
 
 	predicates []*executionPredicate
+
+	// whether the writes of a replayed reconcile are visible to its later reads
+	shadowState bool
 }
 
 func newHarness(reconcilerID string, frames []Frame, frameData map[string]FrameData, effects map[string]DataEffect) *ReplayHarness {
@@ -46,11 +49,11 @@ func (p *ReplayHarness) EffectfulFrames() []Frame {
 	return out
 }
 
-// return the index of the frame that is closest to the given timestamp while still preceding it
-func (p *ReplayHarness) priorFrame(ts string) int {
+// return the index of the frame that is closest to the given position while still preceding it
+func (p *ReplayHarness) priorFrame(seq sequence) int {
 	nearestIndex := -1
 	for i, f := range p.frames {
-		if f.sequenceID < ts {
+		if f.sequence.before(seq) {
 			nearestIndex = i
 		} else {
 			break
@@ -59,18 +62,18 @@ func (p *ReplayHarness) priorFrame(ts string) int {
 	return nearestIndex
 }
 
-func (p *ReplayHarness) nextFrame(ts string) int {
+func (p *ReplayHarness) nextFrame(seq sequence) int {
 	for i, f := range p.frames {
-		if f.sequenceID > ts {
+		if seq.before(f.sequence) {
 			return i
 		}
 	}
 	return -1
 }
 
-func (p *ReplayHarness) nearestFrame(ts string) Frame {
-	upperIdx := p.nextFrame(ts)
-	lowerIdx := p.priorFrame(ts)
+func (p *ReplayHarness) nearestFrame(seq sequence) Frame {
+	upperIdx := p.nextFrame(seq)
+	lowerIdx := p.priorFrame(seq)
 
 	if upperIdx == -1 {
 		//return the last frame
@@ -90,9 +93,8 @@ func (p *ReplayHarness) nearestFrame(ts string) Frame {
 }
 
 func (p *ReplayHarness) insertFrame(f Frame) {
-	ts := f.sequenceID
-	prevIdx := p.priorFrame(ts)
-	nextIdx := p.nextFrame(ts)
+	prevIdx := p.priorFrame(f.sequence)
+	nextIdx := p.nextFrame(f.sequence)
 
 	out := make([]Frame, 0)
 	if prevIdx == -1 {
@@ -121,13 +123,24 @@ func (p *ReplayHarness) WithPredicate(predicate Predicate) *ReplayHarness {
 	return p
 }
 
+// WithShadowState makes the replay clients of the harness apply the writes of a reconcile to a copy of its frame,
+// so that the reconcile reads its own writes. See Client.WithShadowState.
+func (p *ReplayHarness) WithShadowState() *ReplayHarness {
+	p.shadowState = true
+	return p
+}
+
 func (p *ReplayHarness) ReplayClient(scheme *runtime.Scheme) *Client {
 	recorder := &Recorder{
 		reconcilerID:    p.ReconcilerID,
 		effectContainer: p.replayEffects,
 		predicates:      p.predicates,
 	}
-	return NewClient(p.ReconcilerID, scheme, p.frameDataByFrameID, recorder)
+	c := NewClient(scheme, p.frameDataByFrameID, recorder)
+	if p.shadowState {
+		c.WithShadowState()
+	}
+	return c
 }
 
 func (p *ReplayHarness) Load(r reconcile.Reconciler) *Player {
@@ -140,12 +153,29 @@ func (p *ReplayHarness) Load(r reconcile.Reconciler) *Player {
 type Player struct {
 	reconciler reconcile.Reconciler
 	harness    *ReplayHarness
+
+	// whether traced frames with no writes are left out of the replay
+	skipReadOnly bool
+}
+
+// SkipReadOnlyFrames leaves traced frames that made no writes out of the replay. This speeds up the replay,
+// but a change that makes the reconciler write where it only read before goes unnoticed in the skipped frames,
+// so the report lists them.
+func (r *Player) SkipReadOnlyFrames() *Player {
+	r.skipReadOnly = true
+	return r
 }
 
-func (r *Player) Play() error {
+// Play replays the frames to the reconciler and reports how the effects of the replayed reconciles compare
+// to the traced ones. If the reconciler returns an error, the replay stops and the report covers the frames so far.
+//
+// Play replays every frame, including traced frames that made no writes, which earlier versions skipped.
+// Use SkipReadOnlyFrames to keep skipping them.
+func (r *Player) Play() (*ReplayReport, error) {
+	report := &ReplayReport{ReconcilerID: r.harness.ReconcilerID}
 	for _, f := range r.harness.frames {
-		// skip traced frames with no writes
-		if f.Type == FrameTypeTraced && len(r.harness.tracedEffects[f.ID].Writes) == 0 {
+		if r.skipReadOnly && f.Type == FrameTypeTraced && len(r.harness.tracedEffects[f.ID].Writes) == 0 {
+			report.Skipped = append(report.Skipped, f.ID)
 			continue
 		}
 		ctx := WithFrameID(context.Background(), f.ID)
@@ -155,10 +185,15 @@ func (r *Player) Play() error {
 			fmt.Printf("Traced Writeset:\n%s\n", formatEventList(r.harness.tracedEffects[f.ID].Writes))
 		}
 
-		if _, err := r.reconciler.Reconcile(ctx, f.Req); err != nil {
+		_, err := r.reconciler.Reconcile(ctx, f.Req)
+		frameReport := compareFrame(f, r.harness.tracedEffects[f.ID], r.harness.replayEffects[f.ID])
+		if err != nil {
 			fmt.Println("Error during replay:", err)
-			return err
+			frameReport.Error = err.Error()
+			report.Frames = append(report.Frames, frameReport)
+			return report, err
 		}
+		report.Frames = append(report.Frames, frameReport)
 
 		fmt.Printf("Actual Readset:\n%s\n", formatEventList(r.harness.replayEffects[f.ID].Reads))
 		fmt.Printf("Actual Writeset:\n%s\n", formatEventList(r.harness.replayEffects[f.ID].Writes))
@@ -167,11 +202,12 @@ func (r *Player) Play() error {
 		for _, p := range r.harness.predicates {
 			if p.satisfied {
 				fmt.Println("Predicate satisfied!!!")
-				return nil
+				report.SatisfiedAt = f.ID
+				return report, nil
 			}
 		}
 	}
-	return nil
+	return report, nil
 }
 
 func formatEventList(events []event.Event) string {
@@ -20,44 +20,59 @@ func TestInsertFrame(t *testing.T) {
 			name: "Test InsertFrame",
 			args: args{
 				framesBefore: []Frame{
-					{sequenceID: "0010", Type: FrameTypeTraced, Req: reconcile.Request{}, TraceyRootID: "traceyRootID1"},
-					{sequenceID: "0012", Type: FrameTypeTraced, Req: reconcile.Request{}, TraceyRootID: "traceyRootID2"},
+					{sequence: sequence{clock: 10}, Type: FrameTypeTraced, Req: reconcile.Request{}, TraceyRootID: "traceyRootID1"},
+					{sequence: sequence{clock: 12}, Type: FrameTypeTraced, Req: reconcile.Request{}, TraceyRootID: "traceyRootID2"},
 				},
-				toInsert: Frame{sequenceID: "0011", Type: FrameTypeTraced, Req: reconcile.Request{}, TraceyRootID: "traceyRootID3"},
+				toInsert: Frame{sequence: sequence{clock: 11}, Type: FrameTypeTraced, Req: reconcile.Request{}, TraceyRootID: "traceyRootID3"},
 				framesAfter: []Frame{
-					{sequenceID: "0010", Type: FrameTypeTraced, Req: reconcile.Request{}, TraceyRootID: "traceyRootID1"},
-					{sequenceID: "0011", Type: FrameTypeTraced, Req: reconcile.Request{}, TraceyRootID: "traceyRootID3"},
-					{sequenceID: "0012", Type: FrameTypeTraced, Req: reconcile.Request{}, TraceyRootID: "traceyRootID2"},
+					{sequence: sequence{clock: 10}, Type: FrameTypeTraced, Req: reconcile.Request{}, TraceyRootID: "traceyRootID1"},
+					{sequence: sequence{clock: 11}, Type: FrameTypeTraced, Req: reconcile.Request{}, TraceyRootID: "traceyRootID3"},
+					{sequence: sequence{clock: 12}, Type: FrameTypeTraced, Req: reconcile.Request{}, TraceyRootID: "traceyRootID2"},
 				},
 			},
 		},
 		{
-			name: "A frame with a sequenceID that is less than the first frame's sequenceID",
+			name: "A frame that precedes the first frame",
 			args: args{
 				framesBefore: []Frame{
-					{sequenceID: "0010", Type: FrameTypeTraced, Req: reconcile.Request{}, TraceyRootID: "traceyRootID1"},
-					{sequenceID: "0012", Type: FrameTypeTraced, Req: reconcile.Request{}, TraceyRootID: "traceyRootID2"},
+					{sequence: sequence{clock: 10}, Type: FrameTypeTraced, Req: reconcile.Request{}, TraceyRootID: "traceyRootID1"},
+					{sequence: sequence{clock: 12}, Type: FrameTypeTraced, Req: reconcile.Request{}, TraceyRootID: "traceyRootID2"},
 				},
-				toInsert: Frame{sequenceID: "0009", Type: FrameTypeTraced, Req: reconcile.Request{}, TraceyRootID: "traceyRootID3"},
+				toInsert: Frame{sequence: sequence{clock: 9}, Type: FrameTypeTraced, Req: reconcile.Request{}, TraceyRootID: "traceyRootID3"},
 				framesAfter: []Frame{
-					{sequenceID: "0009", Type: FrameTypeTraced, Req: reconcile.Request{}, TraceyRootID: "traceyRootID3"},
-					{sequenceID: "0010", Type: FrameTypeTraced, Req: reconcile.Request{}, TraceyRootID: "traceyRootID1"},
-					{sequenceID: "0012", Type: FrameTypeTraced, Req: reconcile.Request{}, TraceyRootID: "traceyRootID2"},
+					{sequence: sequence{clock: 9}, Type: FrameTypeTraced, Req: reconcile.Request{}, TraceyRootID: "traceyRootID3"},
+					{sequence: sequence{clock: 10}, Type: FrameTypeTraced, Req: reconcile.Request{}, TraceyRootID: "traceyRootID1"},
+					{sequence: sequence{clock: 12}, Type: FrameTypeTraced, Req: reconcile.Request{}, TraceyRootID: "traceyRootID2"},
 				},
 			},
 		},
 		{
-			name: "A frame with a sequenceID that is greater than the last frame's sequenceID",
+			name: "A frame that follows the last frame",
 			args: args{
 				framesBefore: []Frame{
-					{sequenceID: "0010", Type: FrameTypeTraced, Req: reconcile.Request{}, TraceyRootID: "traceyRootID1"},
-					{sequenceID: "0012", Type: FrameTypeTraced, Req: reconcile.Request{}, TraceyRootID: "traceyRootID2"},
+					{sequence: sequence{clock: 10}, Type: FrameTypeTraced, Req: reconcile.Request{}, TraceyRootID: "traceyRootID1"},
+					{sequence: sequence{clock: 12}, Type: FrameTypeTraced, Req: reconcile.Request{}, TraceyRootID: "traceyRootID2"},
 				},
-				toInsert: Frame{sequenceID: "0013", Type: FrameTypeTraced, Req: reconcile.Request{}, TraceyRootID: "traceyRootID3"},
+				toInsert: Frame{sequence: sequence{clock: 13}, Type: FrameTypeTraced, Req: reconcile.Request{}, TraceyRootID: "traceyRootID3"},
 				framesAfter: []Frame{
-					{sequenceID: "0010", Type: FrameTypeTraced, Req: reconcile.Request{}, TraceyRootID: "traceyRootID1"},
-					{sequenceID: "0012", Type: FrameTypeTraced, Req: reconcile.Request{}, TraceyRootID: "traceyRootID2"},
-					{sequenceID: "0013", Type: FrameTypeTraced, Req: reconcile.Request{}, TraceyRootID: "traceyRootID3"},
+					{sequence: sequence{clock: 10}, Type: FrameTypeTraced, Req: reconcile.Request{}, TraceyRootID: "traceyRootID1"},
+					{sequence: sequence{clock: 12}, Type: FrameTypeTraced, Req: reconcile.Request{}, TraceyRootID: "traceyRootID2"},
+					{sequence: sequence{clock: 13}, Type: FrameTypeTraced, Req: reconcile.Request{}, TraceyRootID: "traceyRootID3"},
+				},
+			},
+		},
+		{
+			name: "Frames from a trace without logical clocks are ordered by timestamp",
+			args: args{
+				framesBefore: []Frame{
+					{sequence: sequence{timestamp: "0010"}, Type: FrameTypeTraced, Req: reconcile.Request{}, TraceyRootID: "traceyRootID1"},
+					{sequence: sequence{timestamp: "0012"}, Type: FrameTypeTraced, Req: reconcile.Request{}, TraceyRootID: "traceyRootID2"},
+				},
+				toInsert: Frame{sequence: sequence{timestamp: "0011"}, Type: FrameTypeTraced, Req: reconcile.Request{}, TraceyRootID: "traceyRootID3"},
+				framesAfter: []Frame{
+					{sequence: sequence{timestamp: "0010"}, Type: FrameTypeTraced, Req: reconcile.Request{}, TraceyRootID: "traceyRootID1"},
+					{sequence: sequence{timestamp: "0011"}, Type: FrameTypeTraced, Req: reconcile.Request{}, TraceyRootID: "traceyRootID3"},
+					{sequence: sequence{timestamp: "0012"}, Type: FrameTypeTraced, Req: reconcile.Request{}, TraceyRootID: "traceyRootID2"},
 				},
 			},
 		},
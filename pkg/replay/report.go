@@ -0,0 +1,272 @@
+package replay
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/tgoodwin/sleeve/pkg/event"
+	"github.com/tgoodwin/sleeve/pkg/snapshot"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// Verdict summarizes how a replay compares to the trace it was built from.
+type Verdict string
+
+const (
+	// the replayed reconciles read and wrote the same objects, and wrote the same content
+	VerdictIdentical Verdict = "IDENTICAL"
+	// the replayed reconciles made the same writes, but read different objects
+	VerdictReadsDiverged Verdict = "READS_DIVERGED"
+	// the replayed reconciles made different writes, wrote different content, or failed
+	VerdictWritesDiverged Verdict = "WRITES_DIVERGED"
+)
+
+// ReplayReport compares the effects of the replayed reconciles to the effects that the trace recorded for them.
+type ReplayReport struct {
+	ReconcilerID string
+	Frames       []FrameReport
+
+	// the traced frames that were not replayed because they made no writes. See Player.SkipReadOnlyFrames.
+	Skipped []string
+
+	// the frame at which a predicate was satisfied, which ended the replay
+	SatisfiedAt string
+}
+
+// FrameReport compares the effects of a single replayed reconcile to its traced effects.
+// Synthetic frames have no traced effects, so all of their effects are extra and they do not count towards the verdict.
+type FrameReport struct {
+	FrameID string
+	Type    FrameType
+	Req     reconcile.Request
+
+	Reads  EffectComparison
+	Writes EffectComparison
+
+	// differences in content between the matching writes, where the trace holds the object a write produced
+	WriteDiffs []WriteDiff
+
+	// the error that the replayed reconcile returned, if any
+	Error string
+}
+
+// EffectComparison pairs the traced effects with the replayed ones. Effects are paired by operation and object,
+// regardless of the version of the object they read or wrote.
+type EffectComparison struct {
+	// the replayed effects that have a traced counterpart
+	Matching []event.Event
+	// the traced effects that the replay did not reproduce
+	Missing []event.Event
+	// the replayed effects that the trace does not have
+	Extra []event.Event
+}
+
+func (c EffectComparison) Diverged() bool {
+	return len(c.Missing) > 0 || len(c.Extra) > 0
+}
+
+// WriteDiff is the difference between the object a traced write produced and the object the replay wrote.
+type WriteDiff struct {
+	Traced   event.Event
+	Replayed event.Event
+	Delta    string
+}
+
+func (f FrameReport) Verdict() Verdict {
+	switch {
+	case f.Error != "" || f.Writes.Diverged() || len(f.WriteDiffs) > 0:
+		return VerdictWritesDiverged
+	case f.Reads.Diverged():
+		return VerdictReadsDiverged
+	}
+	return VerdictIdentical
+}
+
+// Verdict is the verdict of the traced frame that diverged the most.
+func (r *ReplayReport) Verdict() Verdict {
+	verdict := VerdictIdentical
+	for _, f := range r.Frames {
+		if f.Type != FrameTypeTraced {
+			continue
+		}
+		switch f.Verdict() {
+		case VerdictWritesDiverged:
+			return VerdictWritesDiverged
+		case VerdictReadsDiverged:
+			verdict = VerdictReadsDiverged
+		}
+	}
+	return verdict
+}
+
+func (r *ReplayReport) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Replay of %s: %s\n", r.ReconcilerID, r.Verdict())
+	for _, f := range r.Frames {
+		fmt.Fprintf(&sb, "%s frame %s (%s): %s\n", f.Type, f.FrameID, f.Req.NamespacedName, f.Verdict())
+		if f.Error != "" {
+			fmt.Fprintf(&sb, "  error: %s\n", f.Error)
+		}
+		writeEffects(&sb, "missing read", f.Reads.Missing)
+		writeEffects(&sb, "extra read", f.Reads.Extra)
+		writeEffects(&sb, "missing write", f.Writes.Missing)
+		writeEffects(&sb, "extra write", f.Writes.Extra)
+		for _, d := range f.WriteDiffs {
+			fmt.Fprintf(&sb, "  changed write: %s\n%s\n", describeEffect(d.Replayed), strings.TrimSuffix(d.Delta, "\n"))
+		}
+	}
+	if len(r.Skipped) > 0 {
+		fmt.Fprintf(&sb, "skipped %d read-only frames: %s\n", len(r.Skipped), strings.Join(r.Skipped, ", "))
+	}
+	if r.SatisfiedAt != "" {
+		fmt.Fprintf(&sb, "predicate satisfied at frame %s\n", r.SatisfiedAt)
+	}
+	return sb.String()
+}
+
+func writeEffects(sb *strings.Builder, label string, events []event.Event) {
+	for _, e := range events {
+		fmt.Fprintf(sb, "  %s: %s\n", label, describeEffect(e))
+	}
+}
+
+func describeEffect(e event.Event) string {
+	op := e.OpType
+	if e.SubResource != "" {
+		op += "/" + e.SubResource
+	}
+	if e.Absent {
+		return fmt.Sprintf("%s %s %s (absent)", op, e.Kind, e.NamespacedName())
+	}
+	return fmt.Sprintf("%s %s %s", op, e.Kind, e.NamespacedName())
+}
+
+// compareFrame compares the effects of a replayed reconcile to its traced effects.
+func compareFrame(f Frame, traced, replayed DataEffect) FrameReport {
+	report := FrameReport{FrameID: f.ID, Type: f.Type, Req: f.Req}
+	report.Reads, _ = compareEffects(traced.Reads, replayed.Reads)
+	var pairs [][2]int
+	report.Writes, pairs = compareEffects(traced.Writes, replayed.Writes)
+	for _, pair := range pairs {
+		tracedObj, ok := traced.written(pair[0])
+		if !ok {
+			continue
+		}
+		replayedObj, ok := replayed.written(pair[1])
+		if !ok {
+			continue
+		}
+		subResource := replayed.Writes[pair[1]].SubResource
+		delta := snapshot.ComputeDelta(writtenContent(tracedObj, subResource), writtenContent(replayedObj, subResource))
+		if delta != "" {
+			report.WriteDiffs = append(report.WriteDiffs, WriteDiff{
+				Traced:   traced.Writes[pair[0]],
+				Replayed: replayed.Writes[pair[1]],
+				Delta:    delta,
+			})
+		}
+	}
+	return report
+}
+
+// compareEffects pairs each traced effect with the first unpaired replayed effect on the same object.
+// It returns the indices of the paired traced and replayed effects.
+func compareEffects(traced, replayed []event.Event) (EffectComparison, [][2]int) {
+	c := EffectComparison{}
+	paired := make([]bool, len(replayed))
+	pairs := make([][2]int, 0)
+	for i, t := range traced {
+		found := false
+		for j, r := range replayed {
+			if !paired[j] && sameEffect(t, r) {
+				paired[j] = true
+				pairs = append(pairs, [2]int{i, j})
+				c.Matching = append(c.Matching, r)
+				found = true
+				break
+			}
+		}
+		if !found {
+			c.Missing = append(c.Missing, t)
+		}
+	}
+	for j, r := range replayed {
+		if !paired[j] {
+			c.Extra = append(c.Extra, r)
+		}
+	}
+	return c, pairs
+}
+
+// sameEffect reports whether two effects are the same operation on the same object.
+func sameEffect(a, b event.Event) bool {
+	if a.OpType != b.OpType || a.Kind != b.Kind || a.SubResource != b.SubResource || a.Absent != b.Absent {
+		return false
+	}
+	if a.APIVersion != "" && b.APIVersion != "" && a.GroupVersionKind().Group != b.GroupVersionKind().Group {
+		return false
+	}
+	switch {
+	case a.Name != "" && b.Name != "":
+		return a.Namespace == b.Namespace && a.Name == b.Name
+	case a.OpType == "CREATE":
+		// objects created with generateName are named by the apiserver
+		return a.Namespace == b.Namespace
+	case a.Name != "" || b.Name != "":
+		// traces that predate names on events
+		return a.ObjectID != "" && a.ObjectID == b.ObjectID
+	}
+	// lists that observed no objects
+	return a.Namespace == b.Namespace && a.LabelSelector == b.LabelSelector && a.FieldSelector == b.FieldSelector
+}
+
+// writtenContent returns the part of a written object that the write determines. The traced object is the
+// version that the apiserver stored, so fields that the apiserver sets are left out, as are the sleeve labels.
+// Writes to the object leave its status alone, while writes to its status leave everything else alone.
+// Fields that the apiserver defaulted are not known, so they show up as differences for writes that left them unset.
+func writtenContent(obj *unstructured.Unstructured, subResource string) *unstructured.Unstructured {
+	out := obj.DeepCopy()
+	for _, field := range []string{"uid", "creationTimestamp", "selfLink", "managedFields"} {
+		unstructured.RemoveNestedField(out.Object, "metadata", field)
+	}
+	labels := out.GetLabels()
+	for k := range labels {
+		if snapshot.IsIgnoredField(k) {
+			delete(labels, k)
+		}
+	}
+	if len(labels) == 0 {
+		labels = nil
+	}
+	out.SetLabels(labels)
+	switch subResource {
+	case "":
+		delete(out.Object, "status")
+	case "status":
+		for k := range out.Object {
+			if k != "apiVersion" && k != "kind" && k != "metadata" && k != "status" {
+				delete(out.Object, k)
+			}
+		}
+	}
+	pruneEmpty(out.Object)
+	return out
+}
+
+// pruneEmpty removes null values and empty maps, which typed objects are full of once converted to unstructured,
+// but which the apiserver does not store.
+func pruneEmpty(content map[string]interface{}) {
+	for k, v := range content {
+		if m, ok := v.(map[string]interface{}); ok {
+			pruneEmpty(m)
+			if len(m) == 0 {
+				delete(content, k)
+			}
+			continue
+		}
+		if v == nil {
+			delete(content, k)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package replay
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/tgoodwin/sleeve/pkg/event"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func TestPlayReportsDivergence(t *testing.T) {
+	configMap := func(scale string) *unstructured.Unstructured {
+		u := &unstructured.Unstructured{}
+		u.SetAPIVersion("v1")
+		u.SetKind("ConfigMap")
+		u.SetNamespace("default")
+		u.SetName("config")
+		u.SetUID("config-uid")
+		u.SetResourceVersion("5")
+		u.SetLabels(map[string]string{"discrete.events/change-id": "change-" + scale})
+		unstructured.SetNestedField(u.Object, scale, "data", "scale")
+		return u
+	}
+	req := reconcile.Request{NamespacedName: types.NamespacedName{Namespace: "default", Name: "config"}}
+	op := func(opType, name string) event.Event {
+		return event.Event{OpType: opType, Kind: "ConfigMap", APIVersion: "v1", Namespace: "default", Name: name}
+	}
+	// in the trace, the reconcile scaled the config to 2 and created a companion config
+	traced := DataEffect{
+		Reads:   []event.Event{op("GET", "config")},
+		Writes:  []event.Event{op("UPDATE", "config"), op("CREATE", "companion")},
+		Written: map[int]*unstructured.Unstructured{0: configMap("2")},
+	}
+	newPlayer := func(scale string, createCompanion bool) *Player {
+		frames := []Frame{{ID: "frame-1", Type: FrameTypeTraced, Req: req, sequence: sequence{clock: 1}}}
+		frameData := map[string]FrameData{"frame-1": {
+			schema.GroupKind{Kind: "ConfigMap"}: {req.NamespacedName: configMap("1")},
+		}}
+		harness := newHarness("ConfigMap", frames, frameData, map[string]DataEffect{"frame-1": traced})
+		c := harness.ReplayClient(nil)
+		return harness.Load(reconcile.Func(func(ctx context.Context, req reconcile.Request) (reconcile.Result, error) {
+			cm := &corev1.ConfigMap{}
+			if err := c.Get(ctx, req.NamespacedName, cm); err != nil {
+				return reconcile.Result{}, err
+			}
+			cm.Data["scale"] = scale
+			if err := c.Update(ctx, cm); err != nil {
+				return reconcile.Result{}, err
+			}
+			if createCompanion {
+				return reconcile.Result{}, c.Create(ctx, &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "companion"}})
+			}
+			return reconcile.Result{}, c.Create(ctx, &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "companion"}})
+		}))
+	}
+
+	report, err := newPlayer("2", true).Play()
+	if err != nil {
+		t.Fatalf("replay failed: %v", err)
+	}
+	if report.Verdict() != VerdictIdentical {
+		t.Errorf("expected a faithful replay to be identical to the trace, got:\n%s", report)
+	}
+
+	report, err = newPlayer("3", false).Play()
+	if err != nil {
+		t.Fatalf("replay failed: %v", err)
+	}
+	if report.Verdict() != VerdictWritesDiverged {
+		t.Fatalf("expected the writes to diverge, got:\n%s", report)
+	}
+	frame := report.Frames[0]
+	if len(frame.Reads.Matching) != 1 || frame.Reads.Diverged() {
+		t.Errorf("expected the reads to match, got %+v", frame.Reads)
+	}
+	if len(frame.Writes.Matching) != 1 || frame.Writes.Matching[0].OpType != "UPDATE" {
+		t.Errorf("expected the update to match, got %+v", frame.Writes.Matching)
+	}
+	if len(frame.Writes.Missing) != 1 || frame.Writes.Missing[0].Kind != "ConfigMap" {
+		t.Errorf("expected the ConfigMap create to be missing, got %+v", frame.Writes.Missing)
+	}
+	if len(frame.Writes.Extra) != 1 || frame.Writes.Extra[0].Kind != "Secret" {
+		t.Errorf("expected the Secret create to be extra, got %+v", frame.Writes.Extra)
+	}
+	if len(frame.WriteDiffs) != 1 || !strings.Contains(frame.WriteDiffs[0].Delta, "scale") {
+		t.Errorf("expected the update to differ in its scale, got %+v", frame.WriteDiffs)
+	}
+}
+
+func TestPlayReplaysReadOnlyFrames(t *testing.T) {
+	req := reconcile.Request{NamespacedName: types.NamespacedName{Namespace: "default", Name: "config"}}
+	cm := &unstructured.Unstructured{}
+	cm.SetAPIVersion("v1")
+	cm.SetKind("ConfigMap")
+	cm.SetNamespace("default")
+	cm.SetName("config")
+	// in the trace, the reconcile only read the config
+	traced := DataEffect{Reads: []event.Event{{OpType: "GET", Kind: "ConfigMap", APIVersion: "v1", Namespace: "default", Name: "config"}}}
+	newPlayer := func() *Player {
+		frames := []Frame{{ID: "frame-1", Type: FrameTypeTraced, Req: req, sequence: sequence{clock: 1}}}
+		frameData := map[string]FrameData{"frame-1": {schema.GroupKind{Kind: "ConfigMap"}: {req.NamespacedName: cm}}}
+		harness := newHarness("ConfigMap", frames, frameData, map[string]DataEffect{"frame-1": traced})
+		c := harness.ReplayClient(nil)
+		// the changed reconciler also writes
+		return harness.Load(reconcile.Func(func(ctx context.Context, req reconcile.Request) (reconcile.Result, error) {
+			obj := &corev1.ConfigMap{}
+			if err := c.Get(ctx, req.NamespacedName, obj); err != nil {
+				return reconcile.Result{}, err
+			}
+			obj.Data = map[string]string{"scale": "2"}
+			return reconcile.Result{}, c.Update(ctx, obj)
+		}))
+	}
+
+	report, err := newPlayer().Play()
+	if err != nil {
+		t.Fatalf("replay failed: %v", err)
+	}
+	if report.Verdict() != VerdictWritesDiverged || len(report.Frames) != 1 || len(report.Frames[0].Writes.Extra) != 1 {
+		t.Errorf("expected a write where the trace only read to diverge, got:\n%s", report)
+	}
+
+	report, err = newPlayer().SkipReadOnlyFrames().Play()
+	if err != nil {
+		t.Fatalf("replay failed: %v", err)
+	}
+	if len(report.Frames) != 0 || len(report.Skipped) != 1 || report.Skipped[0] != "frame-1" {
+		t.Errorf("expected the read-only frame to be skipped and listed, got:\n%s", report)
+	}
+}
@@ -0,0 +1,269 @@
+package replay
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	jsonpatch "github.com/evanphx/json-patch"
+	"github.com/tgoodwin/sleeve/pkg/util"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	utilrand "k8s.io/apimachinery/pkg/util/rand"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// shadowState is the world as seen by a replayed reconcile: the objects of its frame,
+// with the writes that the reconcile has made so far applied to them, as the apiserver would.
+type shadowState struct {
+	objects FrameData
+
+	// the last resourceVersion handed out
+	resourceVersion uint64
+
+	// to apply strategic merge patches, which need to know the Go type of the object
+	scheme *runtime.Scheme
+}
+
+// newShadowState returns the shadow state of a frame. The objects of the frame are never modified,
+// since writes store new versions of objects rather than changing them in place.
+func newShadowState(frame FrameData, scheme *runtime.Scheme) *shadowState {
+	s := &shadowState{objects: frame.Copy(), scheme: scheme}
+	for _, objs := range s.objects {
+		for _, obj := range objs {
+			if rv, err := strconv.ParseUint(obj.GetResourceVersion(), 10, 64); err == nil && rv > s.resourceVersion {
+				s.resourceVersion = rv
+			}
+		}
+	}
+	return s
+}
+
+// objectsFor returns the objects of the given GroupKind, to which new objects of the kind can be added.
+func (s *shadowState) objectsFor(gk schema.GroupKind) map[types.NamespacedName]*unstructured.Unstructured {
+	if objs, ok := s.objects.objectsFor(gk); ok {
+		return objs
+	}
+	s.objects[gk] = make(map[types.NamespacedName]*unstructured.Unstructured)
+	return s.objects[gk]
+}
+
+// existing returns the current version of the object with the given key, or a NotFound error.
+func (s *shadowState) existing(gvk schema.GroupVersionKind, key types.NamespacedName) (*unstructured.Unstructured, error) {
+	if obj, ok := s.objectsFor(gvk.GroupKind())[key]; ok {
+		return obj, nil
+	}
+	return nil, apierrors.NewNotFound(groupResource(gvk), key.Name)
+}
+
+// put stores a new version of an object. An object that is being deleted is removed once its last finalizer is.
+func (s *shadowState) put(gvk schema.GroupVersionKind, obj *unstructured.Unstructured) *unstructured.Unstructured {
+	objs := s.objectsFor(gvk.GroupKind())
+	key := types.NamespacedName{Namespace: obj.GetNamespace(), Name: obj.GetName()}
+	if obj.GetDeletionTimestamp() != nil && len(obj.GetFinalizers()) == 0 {
+		delete(objs, key)
+		return obj
+	}
+	s.resourceVersion++
+	obj.SetResourceVersion(strconv.FormatUint(s.resourceVersion, 10))
+	objs[key] = obj
+	return obj
+}
+
+func (s *shadowState) create(gvk schema.GroupVersionKind, obj *unstructured.Unstructured) (*unstructured.Unstructured, error) {
+	if obj.GetName() == "" && obj.GetGenerateName() != "" {
+		obj.SetName(obj.GetGenerateName() + utilrand.String(5))
+	}
+	if obj.GetName() == "" {
+		return nil, apierrors.NewBadRequest("name or generateName is required")
+	}
+	key := types.NamespacedName{Namespace: obj.GetNamespace(), Name: obj.GetName()}
+	if _, ok := s.objectsFor(gvk.GroupKind())[key]; ok {
+		return nil, apierrors.NewAlreadyExists(groupResource(gvk), key.Name)
+	}
+	if obj.GetResourceVersion() != "" {
+		return nil, apierrors.NewBadRequest("resourceVersion can not be set for create requests")
+	}
+	obj.SetUID(types.UID(util.UUID()))
+	obj.SetCreationTimestamp(metav1.Now())
+	obj.SetGeneration(1)
+	obj.SetDeletionTimestamp(nil)
+	return s.put(gvk, obj), nil
+}
+
+// update replaces the object, failing with a Conflict if it was read at an older resourceVersion.
+func (s *shadowState) update(gvk schema.GroupVersionKind, obj *unstructured.Unstructured) (*unstructured.Unstructured, error) {
+	existing, err := s.existing(gvk, client.ObjectKeyFromObject(obj))
+	if err != nil {
+		return nil, err
+	}
+	if obj.GetResourceVersion() != "" && obj.GetResourceVersion() != existing.GetResourceVersion() {
+		return nil, conflict(gvk, obj.GetName())
+	}
+	return s.put(gvk, keepServerFields(existing, obj)), nil
+}
+
+// updateStatus replaces the status of the object and nothing else.
+func (s *shadowState) updateStatus(gvk schema.GroupVersionKind, obj *unstructured.Unstructured) (*unstructured.Unstructured, error) {
+	existing, err := s.existing(gvk, client.ObjectKeyFromObject(obj))
+	if err != nil {
+		return nil, err
+	}
+	if obj.GetResourceVersion() != "" && obj.GetResourceVersion() != existing.GetResourceVersion() {
+		return nil, conflict(gvk, obj.GetName())
+	}
+	return s.put(gvk, withStatusOf(existing, obj)), nil
+}
+
+// patch applies a patch to the object. Patches are computed from obj, as the reconciler issued it.
+func (s *shadowState) patch(gvk schema.GroupVersionKind, obj client.Object, patch client.Patch) (*unstructured.Unstructured, error) {
+	existing, err := s.existing(gvk, client.ObjectKeyFromObject(obj))
+	if err != nil {
+		// like the apiserver, an apply patch creates the object it describes
+		if apierrors.IsNotFound(err) && patch.Type() == types.ApplyPatchType {
+			created, err := toUnstructured(obj)
+			if err != nil {
+				return nil, err
+			}
+			created.SetGroupVersionKind(gvk)
+			created.SetResourceVersion("")
+			return s.create(gvk, created)
+		}
+		return nil, err
+	}
+	patched, err := s.applyPatch(gvk, existing, obj, patch)
+	if err != nil {
+		return nil, err
+	}
+	return s.put(gvk, keepServerFields(existing, patched)), nil
+}
+
+// patchStatus applies a patch to the object and keeps only the change to its status.
+func (s *shadowState) patchStatus(gvk schema.GroupVersionKind, obj client.Object, patch client.Patch) (*unstructured.Unstructured, error) {
+	existing, err := s.existing(gvk, client.ObjectKeyFromObject(obj))
+	if err != nil {
+		return nil, err
+	}
+	patched, err := s.applyPatch(gvk, existing, obj, patch)
+	if err != nil {
+		return nil, err
+	}
+	return s.put(gvk, withStatusOf(existing, patched)), nil
+}
+
+// delete removes the object, or marks it as being deleted if it has finalizers.
+func (s *shadowState) delete(gvk schema.GroupVersionKind, key types.NamespacedName) error {
+	existing, err := s.existing(gvk, key)
+	if err != nil {
+		return err
+	}
+	if len(existing.GetFinalizers()) == 0 {
+		delete(s.objectsFor(gvk.GroupKind()), key)
+		return nil
+	}
+	if existing.GetDeletionTimestamp() != nil {
+		return nil
+	}
+	deleting := existing.DeepCopy()
+	now := metav1.Now()
+	deleting.SetDeletionTimestamp(&now)
+	s.put(gvk, deleting)
+	return nil
+}
+
+// applyPatch returns the existing object with the patch applied. Apply patches are applied as merge patches,
+// since field ownership is not tracked during replay.
+func (s *shadowState) applyPatch(gvk schema.GroupVersionKind, existing *unstructured.Unstructured, obj client.Object, patch client.Patch) (*unstructured.Unstructured, error) {
+	data, err := patch.Data(obj)
+	if err != nil {
+		return nil, err
+	}
+	original, err := json.Marshal(existing.Object)
+	if err != nil {
+		return nil, err
+	}
+	var patched []byte
+	switch patch.Type() {
+	case types.JSONPatchType:
+		p, err := jsonpatch.DecodePatch(data)
+		if err != nil {
+			return nil, apierrors.NewBadRequest(err.Error())
+		}
+		patched, err = p.Apply(original)
+		if err != nil {
+			return nil, apierrors.NewBadRequest(err.Error())
+		}
+	case types.MergePatchType, types.ApplyPatchType:
+		patched, err = jsonpatch.MergePatch(original, data)
+	case types.StrategicMergePatchType:
+		// types without patch strategies, e.g. custom resources, are merged like the apiserver does
+		dataStruct, schemeErr := s.scheme.New(gvk)
+		if schemeErr != nil {
+			patched, err = jsonpatch.MergePatch(original, data)
+		} else {
+			patched, err = strategicpatch.StrategicMergePatch(original, data, dataStruct)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported patch type %s", patch.Type())
+	}
+	if err != nil {
+		return nil, apierrors.NewBadRequest(err.Error())
+	}
+	out := &unstructured.Unstructured{}
+	if err := out.UnmarshalJSON(patched); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// keepServerFields returns obj with the fields that the apiserver owns set from the existing object.
+// Like the apiserver, the generation is incremented when the spec changes.
+func keepServerFields(existing, obj *unstructured.Unstructured) *unstructured.Unstructured {
+	obj.SetUID(existing.GetUID())
+	obj.SetCreationTimestamp(existing.GetCreationTimestamp())
+	obj.SetDeletionTimestamp(existing.GetDeletionTimestamp())
+	obj.SetGeneration(existing.GetGeneration())
+	if !equality.Semantic.DeepEqual(existing.Object["spec"], obj.Object["spec"]) {
+		obj.SetGeneration(existing.GetGeneration() + 1)
+	}
+	return obj
+}
+
+// withStatusOf returns a copy of the existing object with the status of obj.
+func withStatusOf(existing, obj *unstructured.Unstructured) *unstructured.Unstructured {
+	out := existing.DeepCopy()
+	if status, ok := obj.Object["status"]; ok {
+		out.Object["status"] = runtime.DeepCopyJSONValue(status)
+	} else {
+		delete(out.Object, "status")
+	}
+	return out
+}
+
+// toUnstructured returns a copy of the object in unstructured form.
+func toUnstructured(obj client.Object) (*unstructured.Unstructured, error) {
+	if u, ok := obj.(*unstructured.Unstructured); ok {
+		return u.DeepCopy(), nil
+	}
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return nil, err
+	}
+	return &unstructured.Unstructured{Object: content}, nil
+}
+
+func conflict(gvk schema.GroupVersionKind, name string) error {
+	return apierrors.NewConflict(groupResource(gvk), name,
+		fmt.Errorf("the object has been modified; please apply your changes to the latest version and try again"))
+}
+
+// groupResource guesses the resource of a kind for error messages, like the replay client's NotFound errors do.
+func groupResource(gvk schema.GroupVersionKind) schema.GroupResource {
+	return schema.GroupResource{Group: gvk.Group, Resource: gvk.Kind}
+}
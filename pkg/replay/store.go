@@ -1,9 +1,7 @@
 package replay
 
 import (
-	"fmt"
 	"sort"
-	"strings"
 	"sync"
 
 	"github.com/pkg/errors"
@@ -40,25 +38,6 @@ func (f *replayStore) Add(r snapshot.Record) error {
 	return nil
 }
 
-func (f *replayStore) HydrateFromTrace(traceData []byte) error {
-	lines := strings.Split(string(traceData), "\n")
-	records, err := ParseRecordsFromLines(lines)
-	if err != nil {
-		return err
-	}
-
-	for _, r := range records {
-		if err := f.Add(r); err != nil {
-			fmt.Printf("error adding record to store: %v\n", r.ObjectID)
-			continue
-		}
-	}
-	fmt.Println("total record observations in trace", len(records))
-	fmt.Println("unique records in store after hydration", len(f.store))
-
-	return nil
-}
-
 func (f *replayStore) AllOfKind(kind string) []*unstructured.Unstructured {
 	f.mu.RLock()
 	defer f.mu.RUnlock()
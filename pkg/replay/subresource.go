@@ -0,0 +1,64 @@
+package replay
+
+import (
+	"context"
+	"fmt"
+
+	sleeveclient "github.com/tgoodwin/sleeve/pkg/client"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var _ client.SubResourceClient = (*subResourceClient)(nil)
+
+// subResourceClient records the reconciler's writes to a subresource of an object, e.g. its status.
+type subResourceClient struct {
+	client *Client
+
+	// name of the subresource, e.g. "status"
+	subResource string
+}
+
+func (c *Client) Status() client.SubResourceWriter {
+	return &subResourceClient{client: c, subResource: "status"}
+}
+
+func (c *Client) SubResource(subResource string) client.SubResourceClient {
+	return &subResourceClient{client: c, subResource: subResource}
+}
+
+// Get fails, since traces do not record reads of subresources.
+func (s *subResourceClient) Get(ctx context.Context, obj client.Object, sub client.Object, opts ...client.SubResourceGetOption) error {
+	return fmt.Errorf("subresource %s cannot be read during replay", s.subResource)
+}
+
+// Create records the write. Creating a subresource (e.g. an eviction) does not change the shadow state.
+func (s *subResourceClient) Create(ctx context.Context, obj client.Object, sub client.Object, opts ...client.SubResourceCreateOption) error {
+	return s.write(ctx, obj, sleeveclient.CREATE, nil)
+}
+
+func (s *subResourceClient) Update(ctx context.Context, obj client.Object, opts ...client.SubResourceUpdateOption) error {
+	return s.write(ctx, obj, sleeveclient.UPDATE, func(st *shadowState, gvk schema.GroupVersionKind, issued *unstructured.Unstructured) (*unstructured.Unstructured, error) {
+		return st.updateStatus(gvk, issued)
+	})
+}
+
+func (s *subResourceClient) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.SubResourcePatchOption) error {
+	return s.write(ctx, obj, sleeveclient.PATCH, func(st *shadowState, gvk schema.GroupVersionKind, _ *unstructured.Unstructured) (*unstructured.Unstructured, error) {
+		return st.patchStatus(gvk, obj, patch)
+	})
+}
+
+// write records a write to the subresource. With shadow state, only writes to the status are applied,
+// since the other subresources are not part of the object.
+func (s *subResourceClient) write(ctx context.Context, obj client.Object, op sleeveclient.OperationType, apply shadowWrite) error {
+	if s.subResource != "status" {
+		apply = nil
+	}
+	issued, err := s.client.applyShadow(ctx, obj, apply)
+	if err != nil {
+		return err
+	}
+	return s.client.effectRecorder.RecordSubResourceEffect(ctx, issued, s.subResource, op)
+}
@@ -123,40 +123,41 @@ func (b *Builder) InterpolateFrames(controllerID string, missedKnowledge util.Se
 			return nil, fmt.Errorf("failed to find object with causalID %s", causalKey)
 		}
 		// find
-		ts, err := b.getEarlistTimestampForKey(causalKey)
+		seq, err := b.getEarliestSequenceForKey(causalKey)
 		if err != nil {
-			return nil, fmt.Errorf("failed to find earliest timestamp for key %s: %w", causalKey, err)
+			return nil, fmt.Errorf("failed to find earliest sequence for key %s: %w", causalKey, err)
 		}
 
 		// fmt.Printf("missing object version: %s\n", causalKey)
 		// fmt.Printf("earliest timestamp for missing object version: %s\n", ts)
 		for i, frame := range harness.frames {
-			fmt.Printf("frame %d: %s @ time %s\n", i, frame.ID, frame.sequenceID)
+			fmt.Printf("frame %d: %s @ %s\n", i, frame.ID, frame.sequence)
 		}
 
-		nearestFrame := harness.nearestFrame(ts)
-		fmt.Printf("nearest frame to time %s is %s\n", ts, nearestFrame.ID)
+		nearestFrame := harness.nearestFrame(seq)
+		fmt.Printf("nearest frame to %s is %s\n", seq, nearestFrame.ID)
 		data := harness.frameDataByFrameID[nearestFrame.ID].Copy()
 
 		overwriteKey := types.NamespacedName{
 			Namespace: storeObj.GetNamespace(),
 			Name:      storeObj.GetName(),
 		}
-		if _, ok := data[storeObj.GetKind()][overwriteKey]; !ok {
+		gk := storeObj.GroupVersionKind().GroupKind()
+		if _, ok := data[gk][overwriteKey]; !ok {
 			// print out the frame data
 			fmt.Printf("frame data for frame %s\n", nearestFrame.ID)
-			for objs := range data[storeObj.GetKind()] {
+			for objs := range data[gk] {
 				fmt.Printf("%s\n", objs)
 			}
-			return nil, fmt.Errorf("%s with namespace/name %s/%s not found in frame data", storeObj.GetKind(), storeObj.GetNamespace(), storeObj.GetName())
+			return nil, fmt.Errorf("%s with namespace/name %s/%s not found in frame data", gk, storeObj.GetNamespace(), storeObj.GetName())
 		}
-		data[storeObj.GetKind()][overwriteKey] = storeObj
+		data[gk][overwriteKey] = storeObj
 
 		newFrameID := util.UUID()
 		newFrame := Frame{
 			Type:         FrameTypeSynthetic,
 			ID:           newFrameID,
-			sequenceID:   ts,
+			sequence:     seq,
 			Req:          nearestFrame.Req,
 			TraceyRootID: nearestFrame.TraceyRootID,
 		}
@@ -166,7 +167,7 @@ func (b *Builder) InterpolateFrames(controllerID string, missedKnowledge util.Se
 		// summary
 		fmt.Println("\nIterplation strategy:")
 		for i, frame := range harness.frames {
-			fmt.Printf("frame %d: %s:%s @ time %s\n", i, frame.Type, frame.ID, frame.sequenceID)
+			fmt.Printf("frame %d: %s:%s @ %s\n", i, frame.Type, frame.ID, frame.sequence)
 		}
 		fmt.Println("")
 	}
@@ -174,15 +175,15 @@ func (b *Builder) InterpolateFrames(controllerID string, missedKnowledge util.Se
 	return harness, nil
 }
 
-func (b *Builder) getEarlistTimestampForKey(key event.CausalKey) (string, error) {
+func (b *Builder) getEarliestSequenceForKey(key event.CausalKey) (sequence, error) {
 	for _, event := range b.events {
 		if event.ChangeID() == key.Version {
 			if event.OpType == "GET" || event.OpType == "LIST" {
-				return event.Timestamp, nil
+				return sequence{clock: event.Clock, timestamp: event.Timestamp}, nil
 			}
 		}
 	}
-	return "", fmt.Errorf("failed to find change event for key %s", key)
+	return sequence{}, fmt.Errorf("failed to find change event for key %s", key)
 
 }
 
@@ -191,6 +192,7 @@ func asKnowledge(elems []*unstructured.Unstructured) util.Set[event.CausalKey] {
 	for _, elem := range elems {
 		cid, _ := event.GetChangeID(elem)
 		key := event.CausalKey{
+			Group:    elem.GroupVersionKind().Group,
 			Kind:     elem.GetKind(),
 			ObjectID: string(elem.GetUID()),
 			Version:  cid,
@@ -212,10 +214,10 @@ func showDiffs(versions []*unstructured.Unstructured) ([]diff, error) {
 		return nil, nil
 	}
 
-	kind := versions[0].GetKind()
+	gk := versions[0].GroupVersionKind().GroupKind()
 	for _, v := range versions {
-		if v.GetKind() != kind {
-			return nil, fmt.Errorf("error: versions contain different kinds: %s and %s", kind, v.GetKind())
+		if v.GroupVersionKind().GroupKind() != gk {
+			return nil, fmt.Errorf("error: versions contain different kinds: %s and %s", gk, v.GroupVersionKind().GroupKind())
 		}
 	}
 
@@ -233,8 +235,8 @@ func showDiffs(versions []*unstructured.Unstructured) ([]diff, error) {
 		diffstr := snapshot.ComputeDelta(a, b)
 		d := diff{
 			// TODO ITS UNCLEAR WHETHER OR NOT WE SHOULD USE UID OR NAME HERE, AS UID IS NOT ALWAYS SET
-			prev:  snapshot.VersionKey{Kind: kind, ObjectID: string(a.GetUID()), Version: a.GetResourceVersion()},
-			curr:  snapshot.VersionKey{Kind: kind, ObjectID: string(b.GetUID()), Version: b.GetResourceVersion()},
+			prev:  snapshot.VersionKey{Group: gk.Group, Kind: gk.Kind, ObjectID: string(a.GetUID()), Version: a.GetResourceVersion()},
+			curr:  snapshot.VersionKey{Group: gk.Group, Kind: gk.Kind, ObjectID: string(b.GetUID()), Version: b.GetResourceVersion()},
 			delta: diffstr,
 		}
 		diffList = append(diffList, d)
@@ -1,7 +1,6 @@
 package snapshot
 
 import (
-	"bufio"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -9,6 +8,7 @@ import (
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/tgoodwin/sleeve/pkg/util"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 )
 
@@ -50,14 +50,21 @@ type uniqueKey struct {
 
 func ReadFile(f io.Reader) ([]Record, error) {
 	seen := make(map[VersionKey]struct{})
-	scanner := bufio.NewScanner(f)
+	lines := util.NewLineReader(f)
 	records := make([]Record, 0)
-	for scanner.Scan() {
-		r, err := LoadFromString(scanner.Text())
+	for {
+		line, err := lines.ReadLine()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		r, err := LoadFromString(line)
 		if err != nil {
 			return nil, err
 		}
-		key := VersionKey{r.Kind, r.ObjectID, r.Version}
+		key := r.VersionKey()
 		if _, ok := seen[key]; !ok {
 			records = append(records, r)
 			seen[key] = struct{}{}
@@ -70,10 +77,10 @@ func GroupByID(records []Record) map[string][]Record {
 	seen := make(map[VersionKey]struct{})
 	groups := make(map[string][]Record)
 	for _, r := range records {
-		if _, ok := seen[VersionKey{r.Kind, r.ObjectID, r.Version}]; ok {
+		if _, ok := seen[r.VersionKey()]; ok {
 			continue
 		}
-		seen[VersionKey{r.Kind, r.ObjectID, r.Version}] = struct{}{}
+		seen[r.VersionKey()] = struct{}{}
 
 		if _, ok := groups[r.ObjectID]; !ok {
 			groups[r.ObjectID] = make([]Record, 0)
@@ -118,13 +125,17 @@ var toIgnore = map[string]struct{}{
 	"discrete.events/creator-id":              {},
 	"discrete.events/root-event-id":           {},
 	"discrete.events/prev-write-reconcile-id": {},
+	"discrete.events/logical-clock":           {},
+}
+
+// IsIgnoredField reports whether changes to the field are left out of deltas.
+func IsIgnoredField(k string) bool {
+	_, ok := toIgnore[k]
+	return ok
 }
 
 func shouldIgnore(k string, v interface{}) bool {
-	if _, ok := toIgnore[k]; ok {
-		return true
-	}
-	return false
+	return IsIgnoredField(k)
 }
 
 func computeDelta(dr DiffReporter, old, new *unstructured.Unstructured) string {
@@ -1,7 +1,9 @@
 package snapshot
 
 import (
+	"fmt"
 	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -49,3 +51,17 @@ func TestEliminates(t *testing.T) {
 		})
 	}
 }
+
+func TestReadFileLongLines(t *testing.T) {
+	value := fmt.Sprintf(`{"apiVersion":"v1","kind":"ConfigMap","data":{"blob":"%s"}}`, strings.Repeat("x", 128*1024))
+	line := func(version string) string {
+		return fmt.Sprintf(`{"object_id":"uid-a","kind":"ConfigMap","api_version":"v1","version":%q,"value":%q}`, version, value)
+	}
+	records, err := ReadFile(strings.NewReader(line("1") + "\n" + line("2")))
+	if err != nil {
+		t.Fatalf("failed to read records: %v", err)
+	}
+	if len(records) != 2 || records[1].Value != value {
+		t.Errorf("expected 2 records to be read in full, got %d", len(records))
+	}
+}
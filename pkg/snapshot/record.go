@@ -4,19 +4,21 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
-	"regexp"
+	"strings"
 
 	"github.com/tgoodwin/sleeve/pkg/util"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
 // Record represents a snapshot of a Kubernetes object as it appears in a Sleeve log
 type Record struct {
-	ObjectID string `json:"object_id"`
-	Kind     string `json:"kind"`
-	Version  string `json:"version"`
-	Value    string `json:"value"`
+	ObjectID   string `json:"object_id"`
+	Kind       string `json:"kind"`
+	APIVersion string `json:"api_version,omitempty"`
+	Version    string `json:"version"`
+	Value      string `json:"value"`
 }
 
 func (r Record) ToUnstructured() *unstructured.Unstructured {
@@ -27,14 +29,29 @@ func (r Record) ToUnstructured() *unstructured.Unstructured {
 	return u
 }
 
-func (r Record) GetID() string {
-	kind := r.Kind
-	re := regexp.MustCompile(`Kind=([^,]+)`)
-	matches := re.FindStringSubmatch(kind)
-	if len(matches) > 1 {
-		kind = matches[1]
+// GroupVersionKind returns the GVK of the recorded object. Older traces only recorded a Kind,
+// which was sometimes a stringified GVK (e.g. "apps/v1, Kind=Deployment").
+func (r Record) GroupVersionKind() schema.GroupVersionKind {
+	if r.APIVersion != "" {
+		return schema.FromAPIVersionAndKind(r.APIVersion, r.Kind)
+	}
+	if gv, kind, ok := strings.Cut(r.Kind, ", Kind="); ok {
+		return schema.FromAPIVersionAndKind(strings.TrimPrefix(gv, "/"), kind)
+	}
+	return schema.GroupVersionKind{Kind: r.Kind}
+}
+
+func (r Record) VersionKey() VersionKey {
+	return VersionKey{
+		Group:    r.GroupVersionKind().Group,
+		Kind:     r.GroupVersionKind().Kind,
+		ObjectID: r.ObjectID,
+		Version:  r.Version,
 	}
-	return fmt.Sprintf("%s:%s@%s", kind, util.Shorter(r.ObjectID), r.Version)
+}
+
+func (r Record) GetID() string {
+	return fmt.Sprintf("%s:%s@%s", r.GroupVersionKind().GroupKind(), util.Shorter(r.ObjectID), r.Version)
 }
 
 var toMask = map[string]struct{}{
@@ -80,11 +97,13 @@ func Serialize(obj interface{}) string {
 }
 
 func RecordValue(obj client.Object) string {
+	gvk := util.GetGroupVersionKind(obj)
 	r := Record{
-		ObjectID: string(obj.GetUID()),
-		Kind:     util.GetKind(obj),
-		Version:  obj.GetResourceVersion(),
-		Value:    Serialize(obj),
+		ObjectID:   string(obj.GetUID()),
+		Kind:       gvk.Kind,
+		APIVersion: gvk.GroupVersion().String(),
+		Version:    obj.GetResourceVersion(),
+		Value:      Serialize(obj),
 	}
 	asJSON, _ := json.Marshal(r)
 	return string(asJSON)
@@ -0,0 +1,43 @@
+package snapshot
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestRecordGroupVersionKind(t *testing.T) {
+	tests := []struct {
+		name   string
+		record Record
+		want   schema.GroupVersionKind
+	}{
+		{
+			name:   "api version",
+			record: Record{Kind: "Cluster", APIVersion: "cluster.x-k8s.io/v1beta1"},
+			want:   schema.GroupVersionKind{Group: "cluster.x-k8s.io", Version: "v1beta1", Kind: "Cluster"},
+		},
+		{
+			name:   "stringified gvk",
+			record: Record{Kind: "apps/v1, Kind=Deployment"},
+			want:   schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"},
+		},
+		{
+			name:   "stringified core gvk",
+			record: Record{Kind: "/v1, Kind=ConfigMap"},
+			want:   schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"},
+		},
+		{
+			name:   "kind only",
+			record: Record{Kind: "ConfigMap"},
+			want:   schema.GroupVersionKind{Kind: "ConfigMap"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.record.GroupVersionKind(); got != tt.want {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
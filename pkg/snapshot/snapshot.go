@@ -24,6 +24,18 @@ type Observation struct {
 
 type LocalKnowledge = []Observation
 
+func NewObservation(timestamp string, elements VersionSet) Observation {
+	return Observation{timestamp: timestamp, elements: elements}
+}
+
+func (o Observation) Timestamp() string {
+	return o.timestamp
+}
+
+func (o Observation) Elements() VersionSet {
+	return o.elements
+}
+
 func (o ObjectVersion) NewerThan(other ObjectVersion) bool {
 	return o.Version > other.Version
 }
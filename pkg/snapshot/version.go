@@ -4,6 +4,7 @@ import "github.com/tgoodwin/sleeve/pkg/util"
 
 // VersionKey uniquely identifies the state of an object at a given resource version
 type VersionKey struct {
+	Group    string
 	Kind     string
 	ObjectID string // TODO don't always diff on object ID as it is not yet present in create events
 	Version  string
@@ -2,6 +2,7 @@ package tag
 
 import (
 	"fmt"
+	"strconv"
 
 	"github.com/google/uuid"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -26,8 +27,46 @@ const (
 	TraceyParentID = "discrete.events/parent-id"
 
 	ChangeID = "discrete.events/change-id"
+
+	// the logical clock value of the write that produced the object's current version
+	LogicalClock = "discrete.events/logical-clock"
 )
 
+// sleeveLabels are the labels that sleeve manages on the objects that instrumented controllers write.
+var sleeveLabels = []string{ChangeID, TraceyCreatorID, TraceyRootID, TraceyReconcileID, LogicalClock}
+
+// GetSleeveLabels returns the subset of the object's labels that are managed by sleeve.
+func GetSleeveLabels(obj client.Object) map[string]string {
+	out := make(map[string]string)
+	labels := obj.GetLabels()
+	for _, k := range sleeveLabels {
+		if v, ok := labels[k]; ok {
+			out[k] = v
+		}
+	}
+	return out
+}
+
+// GetRootID returns the ID of the root event that the object's current version follows from,
+// or an empty string if the object has not been tagged by the webhook or an instrumented controller.
+func GetRootID(obj client.Object) string {
+	labels := obj.GetLabels()
+	if rootID, ok := labels[TraceyWebhookLabel]; ok {
+		return rootID
+	}
+	return labels[TraceyRootID]
+}
+
+// GetLogicalClock returns the logical clock value stamped on the object by the last instrumented write,
+// or zero if there is none.
+func GetLogicalClock(obj client.Object) uint64 {
+	clock, err := strconv.ParseUint(obj.GetLabels()[LogicalClock], 10, 64)
+	if err != nil {
+		return 0
+	}
+	return clock
+}
+
 // LabelChange sets a change-id on the object to associate an object's current value with the change event that produced it.
 func LabelChange(obj client.Object) {
 	labels := obj.GetLabels()
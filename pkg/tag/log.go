@@ -5,6 +5,7 @@ import (
 	"strings"
 
 	"github.com/samber/lo"
+	"github.com/tgoodwin/sleeve/pkg/trace/envelope"
 )
 
 // logging labels
@@ -12,15 +13,38 @@ const (
 	LoggerName             = "sleevelog"
 	ControllerOperationKey = "sleeve:controller-operation"
 	ObjectVersionKey       = "sleeve:object-version"
+	FaultKey               = "sleeve:fault"
+	ReconcileKey           = "sleeve:reconcile"
+	RootEventKey           = "sleeve:root-event"
+	ListObservationKey     = "sleeve:list-observation"
 )
 
-var logTypes = []string{ControllerOperationKey, ObjectVersionKey}
+var logTypes = []string{ControllerOperationKey, ObjectVersionKey, FaultKey, ReconcileKey, RootEventKey, ListObservationKey}
 var pattern = regexp.MustCompile(`{"LogType": "(?:` + strings.Join(logTypes, "|") + `)"}`)
 
+// LogTypeOf returns the LogType of a log line that holds a sleeve record, or an empty string.
+//
+// Deprecated: use trace.ParseEnvelope and Envelope.LogType.
+func LogTypeOf(line string) string {
+	env, ok := envelope.Parse(line)
+	if !ok || !lo.Contains(logTypes, env.LogType()) {
+		return ""
+	}
+	return env.LogType()
+}
+
+// StripLogKey returns the sleeve record that a log line holds. Fragments of a zap console line,
+// such as the text that follows the logger name, are returned without their LogType field.
+//
+// Deprecated: use trace.ParseEnvelope, or trace.NewReader to decode the records of a trace.
 func StripLogKey(line string) string {
+	if env, ok := envelope.Parse(line); ok && lo.Contains(logTypes, env.LogType()) {
+		return env.Message
+	}
 	return pattern.ReplaceAllString(line, "")
 }
 
+// Deprecated: use trace.NewReader to decode the records of a trace.
 func StripLogKeyFromLines(lines []string) []string {
 	return lo.Map(lines, func(line string, _ int) string {
 		return StripLogKey(line)
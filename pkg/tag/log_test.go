@@ -0,0 +1,34 @@
+package tag
+
+import "testing"
+
+func TestStripLogKey(t *testing.T) {
+	payload := `{"op_type":"GET"}`
+	tests := []struct {
+		name string
+		line string
+	}{
+		{
+			name: "zap console line",
+			line: "2024-01-01T00:00:00Z\tINFO\tsleevelog\t" + payload + "\t{\"LogType\": \"" + ControllerOperationKey + "\"}",
+		},
+		{
+			name: "zap json line",
+			line: `{"ts":"2024-01-01T00:00:00Z","logger":"sleevelog","msg":` + `"{\"op_type\":\"GET\"}"` + `,"LogType":"` + ControllerOperationKey + `"}`,
+		},
+		{
+			name: "text after the logger name",
+			line: payload + "\t{\"LogType\": \"" + ControllerOperationKey + "\"}",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := StripLogKey(tt.line); got != payload && got != payload+"\t" {
+				t.Errorf("StripLogKey() = %q, want %q", got, payload)
+			}
+		})
+	}
+	if got := LogTypeOf(tests[1].line); got != ControllerOperationKey {
+		t.Errorf("LogTypeOf() = %q, want %q", got, ControllerOperationKey)
+	}
+}
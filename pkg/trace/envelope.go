@@ -0,0 +1,13 @@
+package trace
+
+import "github.com/tgoodwin/sleeve/pkg/trace/envelope"
+
+// Envelope is a log line as written by the logger that emitted it: the message along with
+// the logger's own metadata, and the pod and container it came from if the log collector recorded them.
+type Envelope = envelope.Envelope
+
+// ParseEnvelope parses a log line written by controller-runtime's zap JSON or console encoders or by klog,
+// optionally wrapped by a log collector (a JSON document with the line under "log", or kubectl logs --prefix).
+func ParseEnvelope(line string) (Envelope, bool) {
+	return envelope.Parse(line)
+}
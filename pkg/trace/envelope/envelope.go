@@ -0,0 +1,168 @@
+// Package envelope parses the log lines that carry sleeve records. It has no dependencies within sleeve,
+// so that every package can recognize sleeve records in logs.
+package envelope
+
+import (
+	"encoding/json"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Envelope is a log line as written by the logger that emitted it: the message along with
+// the logger's own metadata, and the pod and container it came from if the log collector recorded them.
+type Envelope struct {
+	// as written by the logger, e.g. an RFC 3339 time, epoch seconds or a klog header time
+	Timestamp string
+	Level     string
+	Logger    string
+	Message   string
+
+	// the structured key/value pairs logged along with the message
+	Fields map[string]interface{}
+
+	Namespace string
+	Pod       string
+	Container string
+}
+
+// LogType returns the sleeve LogType that the line was logged with, if any.
+func (e Envelope) LogType() string {
+	logType, _ := e.Fields["LogType"].(string)
+	return logType
+}
+
+// Parse parses a log line written by controller-runtime's zap JSON or console encoders or by klog,
+// optionally wrapped by a log collector (a JSON document with the line under "log", or kubectl logs --prefix).
+func Parse(line string) (Envelope, bool) {
+	line = strings.TrimSpace(line)
+	if m := kubectlPrefix.FindStringSubmatch(line); m != nil {
+		env, ok := Parse(m[3])
+		env.Pod, env.Container = m[1], m[2]
+		return env, ok
+	}
+	if strings.HasPrefix(line, "{") {
+		return parseJSONEnvelope(line)
+	}
+	if m := klogHeader.FindStringSubmatch(line); m != nil {
+		return parseKlogEnvelope(m), true
+	}
+	return parseConsoleEnvelope(line)
+}
+
+// kubectl logs --prefix prefixes each line with [pod/<pod>/<container>]
+var kubectlPrefix = regexp.MustCompile(`^\[pod/([^/\]]+)/([^\]]+)\] (.*)$`)
+
+func parseJSONEnvelope(line string) (Envelope, bool) {
+	var fields map[string]interface{}
+	if err := json.Unmarshal([]byte(line), &fields); err != nil {
+		return Envelope{}, false
+	}
+	// log collectors such as fluent-bit wrap the original line along with the pod it came from
+	if wrapped, ok := fields["log"].(string); ok {
+		env, ok := Parse(wrapped)
+		if k8s, found := fields["kubernetes"].(map[string]interface{}); found {
+			env.Namespace, _ = k8s["namespace_name"].(string)
+			env.Pod, _ = k8s["pod_name"].(string)
+			env.Container, _ = k8s["container_name"].(string)
+		}
+		return env, ok
+	}
+	env := Envelope{Fields: fields}
+	env.Timestamp = takeString(fields, "ts", "time", "timestamp")
+	env.Level = takeString(fields, "level")
+	env.Logger = takeString(fields, "logger")
+	env.Message = takeString(fields, "msg", "message")
+	return env, true
+}
+
+// takeString removes the first of the given keys that is present from fields and returns its value as a string.
+func takeString(fields map[string]interface{}, keys ...string) string {
+	for _, k := range keys {
+		v, ok := fields[k]
+		if !ok {
+			continue
+		}
+		delete(fields, k)
+		switch v := v.(type) {
+		case string:
+			return v
+		case float64:
+			return strconv.FormatFloat(v, 'f', -1, 64)
+		default:
+			b, _ := json.Marshal(v)
+			return string(b)
+		}
+	}
+	return ""
+}
+
+// zap's console encoder writes tab-separated columns: time, level, logger name and caller (if enabled),
+// the message, and the structured fields as a JSON object (if any).
+func parseConsoleEnvelope(line string) (Envelope, bool) {
+	columns := strings.Split(line, "\t")
+	if len(columns) < 3 {
+		return Envelope{}, false
+	}
+	env := Envelope{Timestamp: columns[0], Level: columns[1]}
+	rest := columns[2:]
+	if n := len(rest); n >= 2 {
+		var fields map[string]interface{}
+		// with only two columns left, a logger name followed by a JSON message has no fields
+		if err := json.Unmarshal([]byte(rest[n-1]), &fields); err == nil && (n > 2 || !isIdentifier(rest[0])) {
+			env.Fields = fields
+			rest = rest[:n-1]
+		}
+	}
+	env.Message = rest[len(rest)-1]
+	for _, column := range rest[:len(rest)-1] {
+		if !caller.MatchString(column) {
+			env.Logger = column
+		}
+	}
+	return env, true
+}
+
+var caller = regexp.MustCompile(`\.go:\d+$`)
+
+var identifier = regexp.MustCompile(`^[\w.\-/:]+$`)
+
+func isIdentifier(s string) bool {
+	return identifier.MatchString(s)
+}
+
+// klog's header: Lmmdd hh:mm:ss.uuuuuu threadid file:line]
+var klogHeader = regexp.MustCompile(`^([IWEF])(\d{4} \d{2}:\d{2}:\d{2}\.\d+)\s+\d+\s+[^\]]+\] (.*)$`)
+
+var klogLevels = map[string]string{"I": "info", "W": "warning", "E": "error", "F": "fatal"}
+
+// klog's structured format is a quoted message followed by key=value pairs, with string values quoted.
+// Unstructured klog lines are plain text.
+func parseKlogEnvelope(m []string) Envelope {
+	env := Envelope{Timestamp: m[2], Level: klogLevels[m[1]], Fields: make(map[string]interface{})}
+	rest := m[3]
+	quoted, err := strconv.QuotedPrefix(rest)
+	if err != nil {
+		env.Message = rest
+		return env
+	}
+	env.Message, _ = strconv.Unquote(quoted)
+	rest = strings.TrimSpace(rest[len(quoted):])
+	for rest != "" {
+		key, value, found := strings.Cut(rest, "=")
+		if !found {
+			break
+		}
+		if quoted, err := strconv.QuotedPrefix(value); err == nil {
+			env.Fields[key], _ = strconv.Unquote(quoted)
+			value = value[len(quoted):]
+		} else {
+			v, remaining, _ := strings.Cut(value, " ")
+			env.Fields[key] = v
+			value = remaining
+		}
+		rest = strings.TrimSpace(value)
+	}
+	env.Logger = takeString(env.Fields, "logger")
+	return env
+}
@@ -0,0 +1,73 @@
+package trace
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseEnvelope(t *testing.T) {
+	payload := `{"op_type":"GET","kind":"Pod"}`
+	tests := []struct {
+		name string
+		line string
+		want Envelope
+	}{
+		{
+			name: "zap console",
+			line: "2024-01-01T00:00:00Z\tINFO\tsleevelog\t" + payload + "\t{\"LogType\": \"sleeve:controller-operation\"}",
+			want: Envelope{Timestamp: "2024-01-01T00:00:00Z", Level: "INFO", Logger: "sleevelog", Message: payload},
+		},
+		{
+			name: "zap console with caller",
+			line: "2024-01-01T00:00:00Z\tINFO\tsleevelog\tclient/client.go:232\t" + payload + "\t{\"LogType\": \"sleeve:controller-operation\", \"controller\": \"pod\"}",
+			want: Envelope{Timestamp: "2024-01-01T00:00:00Z", Level: "INFO", Logger: "sleevelog", Message: payload},
+		},
+		{
+			name: "zap json",
+			line: `{"level":"info","ts":1704067200.5,"logger":"sleevelog","msg":"{\"op_type\":\"GET\",\"kind\":\"Pod\"}","LogType":"sleeve:controller-operation"}`,
+			want: Envelope{Timestamp: "1704067200.5", Level: "info", Logger: "sleevelog", Message: payload},
+		},
+		{
+			name: "zap json with other key order and extra fields",
+			line: `{"LogType":"sleeve:controller-operation","msg":"{\"op_type\":\"GET\",\"kind\":\"Pod\"}","controller":"pod","logger":"manager.sleevelog","ts":"2024-01-01T00:00:00Z"}`,
+			want: Envelope{Timestamp: "2024-01-01T00:00:00Z", Logger: "manager.sleevelog", Message: payload},
+		},
+		{
+			name: "klog",
+			line: `I0101 00:00:00.000000       1 client.go:232] "{\"op_type\":\"GET\",\"kind\":\"Pod\"}" logger="sleevelog" LogType="sleeve:controller-operation"`,
+			want: Envelope{Timestamp: "0101 00:00:00.000000", Level: "info", Logger: "sleevelog", Message: payload},
+		},
+		{
+			name: "kubectl logs --prefix",
+			line: "[pod/controller-abc/manager] 2024-01-01T00:00:00Z\tINFO\tsleevelog\t" + payload + "\t{\"LogType\": \"sleeve:controller-operation\"}",
+			want: Envelope{Timestamp: "2024-01-01T00:00:00Z", Level: "INFO", Logger: "sleevelog", Message: payload, Pod: "controller-abc", Container: "manager"},
+		},
+		{
+			name: "log collector",
+			line: `{"log":"{\"ts\":\"2024-01-01T00:00:00Z\",\"logger\":\"sleevelog\",\"msg\":\"{\\\"op_type\\\":\\\"GET\\\",\\\"kind\\\":\\\"Pod\\\"}\",\"LogType\":\"sleeve:controller-operation\"}","kubernetes":{"namespace_name":"system","pod_name":"controller-abc","container_name":"manager"}}`,
+			want: Envelope{Timestamp: "2024-01-01T00:00:00Z", Logger: "sleevelog", Message: payload, Namespace: "system", Pod: "controller-abc", Container: "manager"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env, ok := ParseEnvelope(tt.line)
+			if !ok {
+				t.Fatalf("failed to parse line")
+			}
+			if env.LogType() != "sleeve:controller-operation" {
+				t.Errorf("expected LogType sleeve:controller-operation, got %q", env.LogType())
+			}
+			env.Fields = nil
+			if !reflect.DeepEqual(env, tt.want) {
+				t.Errorf("got %+v, want %+v", env, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseConsoleEnvelopeWithoutFields(t *testing.T) {
+	env, ok := ParseEnvelope("2024-01-01T00:00:00Z\tINFO\tsleevelog\t{\"op_type\":\"GET\"}")
+	if !ok || env.Logger != "sleevelog" || env.Message != `{"op_type":"GET"}` || env.Fields != nil {
+		t.Errorf("expected a JSON message without fields, got %+v", env)
+	}
+}
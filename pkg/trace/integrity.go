@@ -0,0 +1,164 @@
+package trace
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// Gap is a range of sequence numbers, inclusive, that is missing from a process' records.
+type Gap struct {
+	From uint64
+	To   uint64
+}
+
+// ProcessIntegrity describes how completely the records of one process instance made it into a trace.
+type ProcessIntegrity struct {
+	ProcessID string
+	Records   int
+
+	// the highest sequence number found. Records lost after it cannot be detected.
+	Last uint64
+
+	// sequence numbers that are missing, including any before the first record found
+	Gaps []Gap
+	// sequence numbers found more than once
+	Duplicates []uint64
+	// sequence numbers found after a higher one. Records that a process emits concurrently
+	// can reach the trace out of sequence, so reordering alone does not mean records were lost.
+	Reordered []uint64
+
+	seen map[uint64]struct{}
+}
+
+// Missing returns the number of records that the process emitted but that are not in the trace.
+func (p *ProcessIntegrity) Missing() uint64 {
+	var n uint64
+	for _, g := range p.Gaps {
+		n += g.To - g.From + 1
+	}
+	return n
+}
+
+// Intact reports whether every record the process emitted up to the last one found is in the trace, exactly once.
+func (p *ProcessIntegrity) Intact() bool {
+	return len(p.Gaps) == 0 && len(p.Duplicates) == 0
+}
+
+func (p *ProcessIntegrity) observe(seq uint64) {
+	p.Records++
+	if _, ok := p.seen[seq]; ok {
+		p.Duplicates = append(p.Duplicates, seq)
+		return
+	}
+	if seq < p.Last {
+		p.Reordered = append(p.Reordered, seq)
+	}
+	p.seen[seq] = struct{}{}
+	if seq > p.Last {
+		p.Last = seq
+	}
+}
+
+func (p *ProcessIntegrity) findGaps() {
+	p.Gaps = nil
+	seqs := make([]uint64, 0, len(p.seen))
+	for seq := range p.seen {
+		seqs = append(seqs, seq)
+	}
+	sort.Slice(seqs, func(i, j int) bool { return seqs[i] < seqs[j] })
+	var prev uint64
+	for _, seq := range seqs {
+		if seq > prev+1 {
+			p.Gaps = append(p.Gaps, Gap{From: prev + 1, To: seq - 1})
+		}
+		prev = seq
+	}
+}
+
+// IntegrityReport describes whether a trace holds every record that the processes which contributed to it emitted.
+type IntegrityReport struct {
+	Processes map[string]*ProcessIntegrity
+
+	// records without a stamp, emitted by versions of sleeve that predate them
+	Unstamped int
+
+	// line numbers (1-based) of sleeve records that could not be decoded, e.g. because the line was truncated
+	Malformed []int
+}
+
+// Intact reports whether no records were found to be lost, duplicated or malformed.
+// Records without a stamp cannot be checked, so a trace made up of them is not considered intact.
+func (r *IntegrityReport) Intact() bool {
+	if len(r.Malformed) > 0 || r.Unstamped > 0 {
+		return false
+	}
+	for _, p := range r.Processes {
+		if !p.Intact() {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *IntegrityReport) String() string {
+	var b strings.Builder
+	ids := make([]string, 0, len(r.Processes))
+	for id := range r.Processes {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		p := r.Processes[id]
+		fmt.Fprintf(&b, "process %s: %d records, %d missing, %d duplicated, %d reordered\n",
+			id, p.Records, p.Missing(), len(p.Duplicates), len(p.Reordered))
+	}
+	if r.Unstamped > 0 {
+		fmt.Fprintf(&b, "%d records without a sequence number\n", r.Unstamped)
+	}
+	if len(r.Malformed) > 0 {
+		fmt.Fprintf(&b, "%d malformed records\n", len(r.Malformed))
+	}
+	return b.String()
+}
+
+func newIntegrityReport() *IntegrityReport {
+	return &IntegrityReport{Processes: make(map[string]*ProcessIntegrity)}
+}
+
+func (r *IntegrityReport) observe(stamp Stamp) {
+	if stamp.ProcessID == "" {
+		r.Unstamped++
+		return
+	}
+	p, ok := r.Processes[stamp.ProcessID]
+	if !ok {
+		p = &ProcessIntegrity{ProcessID: stamp.ProcessID, seen: make(map[uint64]struct{})}
+		r.Processes[stamp.ProcessID] = p
+	}
+	p.observe(stamp.Seq)
+}
+
+func (r *IntegrityReport) finish() *IntegrityReport {
+	for _, p := range r.Processes {
+		p.findGaps()
+	}
+	return r
+}
+
+// CheckIntegrity reads every record in a trace and reports any that were lost, duplicated, reordered or truncated.
+func CheckIntegrity(r io.Reader) (*IntegrityReport, error) {
+	reader := NewReader(r)
+	for {
+		_, err := reader.Next()
+		if err == io.EOF {
+			return reader.Integrity(), nil
+		}
+		var decodeErr *DecodeError
+		if err != nil && !errors.As(err, &decodeErr) {
+			return nil, err
+		}
+	}
+}
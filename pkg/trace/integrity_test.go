@@ -0,0 +1,58 @@
+package trace
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/tgoodwin/sleeve/pkg/tag"
+)
+
+func recordLine(processID string, seq uint64) string {
+	payload := Stamp{ProcessID: processID, Seq: seq}.Apply(`{"op_type":"GET"}`)
+	return fmt.Sprintf("2024-01-01T00:00:00Z\tINFO\t%s\t%s\t{\"LogType\": \"%s\"}", tag.LoggerName, payload, tag.ControllerOperationKey)
+}
+
+func TestCheckIntegrity(t *testing.T) {
+	lines := []string{
+		"2024-01-01T00:00:00Z\tINFO\tsetup\tstarting manager",
+		recordLine("a", 1),
+		recordLine("b", 1),
+		recordLine("a", 2),
+		recordLine("b", 3),
+		recordLine("b", 2),
+		recordLine("a", 5),
+		recordLine("a", 5),
+		fmt.Sprintf("2024-01-01T00:00:00Z\tINFO\t%s\t{\"process_id\":\"a\",\"seq\":6,\"op_ty", tag.LoggerName),
+		fmt.Sprintf("2024-01-01T00:00:00Z\tINFO\t%s\tconfiguring sleeve client from env", tag.LoggerName),
+		fmt.Sprintf("2024-01-01T00:00:00Z\tINFO\t%s\t{\"op_type\":\"GET\"}\t{\"LogType\": \"%s\"}", tag.LoggerName, tag.ControllerOperationKey),
+	}
+	r, err := CheckIntegrity(strings.NewReader(strings.Join(lines, "\n")))
+	if err != nil {
+		t.Fatalf("failed to check integrity: %v", err)
+	}
+
+	a, b := r.Processes["a"], r.Processes["b"]
+	if a == nil || b == nil || len(r.Processes) != 2 {
+		t.Fatalf("expected records from processes a and b, got %v", r.Processes)
+	}
+	if a.Records != 4 || len(a.Gaps) != 1 || a.Gaps[0] != (Gap{From: 3, To: 4}) || len(a.Duplicates) != 1 || a.Duplicates[0] != 5 {
+		t.Errorf("expected process a to miss 3-4 and duplicate 5, got %+v", a)
+	}
+	if len(b.Gaps) != 0 || len(b.Duplicates) != 0 || len(b.Reordered) != 1 || b.Reordered[0] != 2 || !b.Intact() {
+		t.Errorf("expected process b to be intact with 2 reordered, got %+v", b)
+	}
+	if len(r.Malformed) != 1 || r.Malformed[0] != 9 {
+		t.Errorf("expected line 9 to be malformed, got %v", r.Malformed)
+	}
+	if r.Unstamped != 1 {
+		t.Errorf("expected 1 unstamped record, got %d", r.Unstamped)
+	}
+	if r.Intact() {
+		t.Errorf("expected the trace not to be intact")
+	}
+
+	if r, _ := CheckIntegrity(strings.NewReader(recordLine("a", 1) + "\n" + recordLine("a", 2))); !r.Intact() {
+		t.Errorf("expected a complete trace to be intact:\n%s", r)
+	}
+}
@@ -0,0 +1,147 @@
+package trace
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/tgoodwin/sleeve/pkg/event"
+	"github.com/tgoodwin/sleeve/pkg/snapshot"
+	"github.com/tgoodwin/sleeve/pkg/tag"
+	"github.com/tgoodwin/sleeve/pkg/util"
+)
+
+// Record is a sleeve record read from a trace. Exactly one of the typed fields is set, according to LogType.
+type Record struct {
+	LogType string
+	Stamp
+
+	// the line of the trace (1-based) that the record was read from
+	Line int
+	// the log line's own timestamp and the pod and container it was collected from, if known
+	Envelope Envelope
+
+	Event           *event.Event
+	Snapshot        *snapshot.Record
+	Reconcile       *event.Reconcile
+	ListObservation *event.ListObservation
+	Fault           *event.Fault
+	RootEvent       *event.RootEvent
+}
+
+// DecodeError is returned for a sleeve record that could not be decoded, e.g. because its line was truncated.
+// The reader can be used to carry on past it.
+type DecodeError struct {
+	Line    int
+	LogType string
+	Err     error
+}
+
+func (e *DecodeError) Error() string {
+	if e.LogType == "" {
+		return fmt.Sprintf("line %d: decoding record: %v", e.Line, e.Err)
+	}
+	return fmt.Sprintf("line %d: decoding %s record: %v", e.Line, e.LogType, e.Err)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// Reader reads the sleeve records from a trace one at a time, so that a trace does not need to fit in memory.
+// Lines of the trace that do not hold a sleeve record, such as the controller's own logs, are skipped.
+type Reader struct {
+	lines     *util.LineReader
+	line      int
+	integrity *IntegrityReport
+}
+
+func NewReader(r io.Reader) *Reader {
+	return &Reader{lines: util.NewLineReader(r), integrity: newIntegrityReport()}
+}
+
+// Next returns the next record in the trace, or io.EOF once the trace has been read.
+// If a record cannot be decoded, Next returns a *DecodeError and the next call moves on to the following record.
+func (r *Reader) Next() (*Record, error) {
+	for {
+		line, err := r.lines.ReadLine()
+		if err != nil {
+			return nil, err
+		}
+		r.line++
+		env, ok := ParseEnvelope(line)
+		if !ok {
+			// a truncated JSON line cannot be parsed at all
+			if strings.Contains(line, tag.LoggerName) {
+				r.integrity.Malformed = append(r.integrity.Malformed, r.line)
+				return nil, &DecodeError{Line: r.line, Err: fmt.Errorf("unrecognized log line")}
+			}
+			continue
+		}
+		if !isRecord(env) {
+			continue
+		}
+		rec, err := decode(env.LogType(), env.Message)
+		if err != nil {
+			r.integrity.Malformed = append(r.integrity.Malformed, r.line)
+			return nil, &DecodeError{Line: r.line, LogType: env.LogType(), Err: err}
+		}
+		rec.Line = r.line
+		rec.Envelope = env
+		r.integrity.observe(rec.Stamp)
+		return rec, nil
+	}
+}
+
+// Integrity reports whether records were lost, duplicated, reordered or truncated among those read so far.
+func (r *Reader) Integrity() *IntegrityReport {
+	return r.integrity.finish()
+}
+
+func decode(logType, payload string) (*Record, error) {
+	rec := &Record{LogType: logType}
+	stamp, err := StampOf(payload)
+	if err != nil {
+		return nil, err
+	}
+	rec.Stamp = stamp
+
+	var target interface{}
+	switch logType {
+	case tag.ControllerOperationKey:
+		rec.Event = &event.Event{}
+		target = rec.Event
+	case tag.ObjectVersionKey:
+		rec.Snapshot = &snapshot.Record{}
+		target = rec.Snapshot
+	case tag.ReconcileKey:
+		rec.Reconcile = &event.Reconcile{}
+		target = rec.Reconcile
+	case tag.ListObservationKey:
+		rec.ListObservation = &event.ListObservation{}
+		target = rec.ListObservation
+	case tag.FaultKey:
+		rec.Fault = &event.Fault{}
+		target = rec.Fault
+	case tag.RootEventKey:
+		rec.RootEvent = &event.RootEvent{}
+		target = rec.RootEvent
+	default:
+		// a JSON payload whose LogType was lost, i.e. a truncated line
+		return nil, fmt.Errorf("record has no LogType")
+	}
+	if err := json.Unmarshal([]byte(payload), target); err != nil {
+		return nil, err
+	}
+	return rec, nil
+}
+
+// isRecord reports whether a log line holds a sleeve record. A truncated line may have lost its LogType,
+// so any message from the sleeve logger that looks like a JSON object is taken to be a record.
+func isRecord(env Envelope) bool {
+	if env.Logger != tag.LoggerName && !strings.HasSuffix(env.Logger, "."+tag.LoggerName) {
+		return false
+	}
+	return env.LogType() != "" || strings.HasPrefix(env.Message, "{")
+}
@@ -0,0 +1,52 @@
+package trace
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/tgoodwin/sleeve/pkg/tag"
+)
+
+func TestReader(t *testing.T) {
+	// object snapshots easily exceed bufio.Scanner's 64KB line limit
+	value := fmt.Sprintf(`{"apiVersion":"v1","kind":"ConfigMap","data":{"blob":"%s"}}`, strings.Repeat("x", 128*1024))
+	snapshot := fmt.Sprintf(`{"object_id":"uid-a","kind":"ConfigMap","api_version":"v1","version":"1","value":%q}`, value)
+	line := func(payload, logType string) string {
+		return fmt.Sprintf("2024-01-01T00:00:00Z\tINFO\t%s\t%s\t{\"LogType\": \"%s\"}", tag.LoggerName, payload, logType)
+	}
+	lines := []string{
+		"2024-01-01T00:00:00Z\tINFO\tsetup\tstarting manager",
+		line(snapshot, tag.ObjectVersionKey),
+		line(`{"op_type":"GET","kind":"ConfigMap"`, tag.ControllerOperationKey),
+		line(`{"reconcile_id":"r1","phase":"begin"}`, tag.ReconcileKey),
+	}
+	r := NewReader(strings.NewReader(strings.Join(lines, "\n")))
+
+	rec, err := r.Next()
+	if err != nil {
+		t.Fatalf("failed to read snapshot: %v", err)
+	}
+	if rec.Snapshot == nil || rec.Snapshot.Value != value || rec.Line != 2 {
+		t.Errorf("expected the snapshot on line 2 to be read in full, got %+v", rec)
+	}
+
+	_, err = r.Next()
+	var decodeErr *DecodeError
+	if !errors.As(err, &decodeErr) || decodeErr.Line != 3 || decodeErr.LogType != tag.ControllerOperationKey {
+		t.Errorf("expected a decode error for the truncated event on line 3, got %v", err)
+	}
+
+	rec, err = r.Next()
+	if err != nil || rec.Reconcile == nil || rec.Reconcile.ReconcileID != "r1" {
+		t.Errorf("expected to carry on to the reconcile record, got %+v, %v", rec, err)
+	}
+	if _, err := r.Next(); err != io.EOF {
+		t.Errorf("expected io.EOF, got %v", err)
+	}
+	if integrity := r.Integrity(); len(integrity.Malformed) != 1 || integrity.Unstamped != 2 {
+		t.Errorf("unexpected integrity report: %+v", integrity)
+	}
+}
@@ -0,0 +1,39 @@
+package trace
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// Stamp identifies a record's position in the output of the process that emitted it.
+// Every record that a process emits carries the process' instance ID and the next number
+// of a sequence that starts at 1, so that records which were lost, duplicated or reordered
+// on their way into a trace can be detected.
+type Stamp struct {
+	ProcessID string `json:"process_id,omitempty"`
+	Seq       uint64 `json:"seq,omitempty"`
+}
+
+// Apply adds the stamp's fields to a JSON-encoded record.
+// Payloads that are not JSON objects are returned unchanged.
+func (s Stamp) Apply(payload string) string {
+	if !strings.HasPrefix(payload, "{") {
+		return payload
+	}
+	fields, err := json.Marshal(s)
+	if err != nil {
+		return payload
+	}
+	rest := strings.TrimSpace(payload[1:])
+	if rest == "}" {
+		return string(fields)
+	}
+	return string(fields[:len(fields)-1]) + "," + rest
+}
+
+// StampOf returns the stamp carried by a JSON-encoded record.
+func StampOf(payload string) (Stamp, error) {
+	var s Stamp
+	err := json.Unmarshal([]byte(payload), &s)
+	return s, err
+}
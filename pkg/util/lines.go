@@ -0,0 +1,30 @@
+package util
+
+import (
+	"bufio"
+	"io"
+	"strings"
+)
+
+// LineReader reads a stream line by line. Unlike bufio.Scanner, it does not limit
+// the length of a line, which object snapshots in traces can easily exceed.
+type LineReader struct {
+	r *bufio.Reader
+}
+
+func NewLineReader(r io.Reader) *LineReader {
+	return &LineReader{r: bufio.NewReader(r)}
+}
+
+// ReadLine returns the next line without its line ending, or io.EOF once every line has been read.
+func (l *LineReader) ReadLine() (string, error) {
+	line, err := l.r.ReadString('\n')
+	if err == io.EOF && line != "" {
+		// the last line has no line ending
+		err = nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
@@ -5,7 +5,8 @@ import (
 	"strings"
 
 	"github.com/google/uuid"
-	"sigs.k8s.io/controller-runtime/pkg/client"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 )
 
 // Shorter is used to shorten a UID for display purposes only.
@@ -19,7 +20,7 @@ func Shorter(s string) string {
 // GetKind returns the kind of the object. It uses reflection to determine the kind if the client.Object instance
 // does not have a GroupVersionKind set yet. This happens during object creation before the object is sent to the
 // Kubernetes API server.
-func GetKind(obj client.Object) string {
+func GetKind(obj runtime.Object) string {
 	kind := obj.GetObjectKind().GroupVersionKind().Kind
 	if kind == "" {
 		t := reflect.TypeOf(obj)
@@ -31,6 +32,14 @@ func GetKind(obj client.Object) string {
 	return kind
 }
 
+// GetGroupVersionKind returns the GroupVersionKind of the object. As with GetKind, the Kind is inferred
+// by reflection if it is not set, but the group and version are left empty in that case.
+func GetGroupVersionKind(obj runtime.Object) schema.GroupVersionKind {
+	gvk := obj.GetObjectKind().GroupVersionKind()
+	gvk.Kind = GetKind(obj)
+	return gvk
+}
+
 func UUID() string {
 	return uuid.New().String()
 }
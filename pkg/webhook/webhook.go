@@ -0,0 +1,156 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/tgoodwin/sleeve/pkg/client"
+	"github.com/tgoodwin/sleeve/pkg/event"
+	"github.com/tgoodwin/sleeve/pkg/tag"
+	"github.com/tgoodwin/sleeve/pkg/util"
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	crwebhook "sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+var log = logf.Log.WithName(tag.LoggerName)
+
+// Handler is a mutating admission handler that marks the root of a causal chain. When a user
+// (rather than a controller) creates or updates an object, it labels the object with a fresh
+// tracey-uid and records a root event. Instrumented controllers propagate the tracey-uid
+// to everything they do as a consequence of the change.
+type Handler struct {
+	// isController reports whether a request was made by a controller rather than a user.
+	isController func(authenticationv1.UserInfo) bool
+
+	logger logr.Logger
+	sink   client.TraceSink
+}
+
+var _ admission.Handler = (*Handler)(nil)
+
+type Option func(*Handler)
+
+// WithSink sets the destination of the root event records. Defaults to the controller-runtime logger.
+func WithSink(sink client.TraceSink) Option {
+	return func(h *Handler) {
+		h.sink = sink
+	}
+}
+
+// WithControllerCheck overrides how the handler tells controllers apart from users.
+func WithControllerCheck(isController func(authenticationv1.UserInfo) bool) Option {
+	return func(h *Handler) {
+		h.isController = isController
+	}
+}
+
+func NewHandler(opts ...Option) *Handler {
+	h := &Handler{
+		isController: IsSystemUser,
+		logger:       log,
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	if h.sink == nil {
+		h.sink = client.NewLogrSink(h.logger)
+	}
+	return h
+}
+
+// IsSystemUser reports whether the request was made by a service account or a
+// kubernetes component, which is where controllers usually run.
+func IsSystemUser(user authenticationv1.UserInfo) bool {
+	return strings.HasPrefix(user.Username, "system:")
+}
+
+// Register serves the handler on the manager's webhook server at the given path.
+func (h *Handler) Register(mgr manager.Manager, path string) {
+	mgr.GetWebhookServer().Register(path, &crwebhook.Admission{Handler: h})
+}
+
+func (h *Handler) Handle(ctx context.Context, req admission.Request) admission.Response {
+	if req.Operation != admissionv1.Create && req.Operation != admissionv1.Update {
+		return admission.Allowed("")
+	}
+	if req.SubResource != "" || h.isController(req.UserInfo) {
+		return admission.Allowed("")
+	}
+
+	obj := &unstructured.Unstructured{}
+	if err := obj.UnmarshalJSON(req.Object.Raw); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+	if req.Operation == admissionv1.Update {
+		old := &unstructured.Unstructured{}
+		if err := old.UnmarshalJSON(req.OldObject.Raw); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+		if isInstrumentedWrite(obj, old) {
+			return admission.Allowed("")
+		}
+	} else if isInstrumentedWrite(obj, nil) {
+		return admission.Allowed("")
+	}
+
+	rootID := util.UUID()
+	labels := obj.GetLabels()
+	if labels == nil {
+		labels = make(map[string]string)
+	}
+	labels[tag.TraceyWebhookLabel] = rootID
+	// a controller-written object still carries the root it was propagated from,
+	// which this change supersedes
+	if _, ok := labels[tag.TraceyRootID]; ok {
+		labels[tag.TraceyRootID] = rootID
+	}
+	obj.SetLabels(labels)
+
+	mutated, err := obj.MarshalJSON()
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	h.logRootEvent(&event.RootEvent{
+		RootEventID: rootID,
+		OpType:      string(req.Operation),
+		Kind:        req.Kind.Kind,
+		APIVersion:  schema.GroupVersion{Group: req.Kind.Group, Version: req.Kind.Version}.String(),
+		ObjectID:    string(obj.GetUID()),
+		Namespace:   req.Namespace,
+		Name:        obj.GetName(),
+		User:        req.UserInfo.Username,
+	})
+	return admission.PatchResponseFromRaw(req.Object.Raw, mutated)
+}
+
+// isInstrumentedWrite reports whether the write was made by a sleeve-instrumented controller,
+// which assigns a new change-id to every object it writes.
+func isInstrumentedWrite(obj, old *unstructured.Unstructured) bool {
+	changeID, ok := obj.GetLabels()[tag.ChangeID]
+	if !ok {
+		return false
+	}
+	if old == nil {
+		return true
+	}
+	return changeID != old.GetLabels()[tag.ChangeID]
+}
+
+func (h *Handler) logRootEvent(e *event.RootEvent) {
+	e.Timestamp = event.FormatTimeStr(time.Now())
+	payload, err := json.Marshal(e)
+	if err != nil {
+		panic("failed to marshal root event")
+	}
+	client.Emit(h.sink, h.logger, tag.RootEventKey, string(payload))
+}
@@ -0,0 +1,163 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	jsonpatch "github.com/evanphx/json-patch"
+	"github.com/tgoodwin/sleeve/pkg/client"
+	"github.com/tgoodwin/sleeve/pkg/event"
+	"github.com/tgoodwin/sleeve/pkg/tag"
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+func configMap(t *testing.T, labels map[string]string) runtime.RawExtension {
+	t.Helper()
+	cm := &corev1.ConfigMap{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "ConfigMap"},
+		ObjectMeta: metav1.ObjectMeta{Name: "cm", Namespace: "default", Labels: labels},
+	}
+	raw, err := json.Marshal(cm)
+	if err != nil {
+		t.Fatalf("failed to marshal object: %v", err)
+	}
+	return runtime.RawExtension{Raw: raw}
+}
+
+func TestHandle(t *testing.T) {
+	user := authenticationv1.UserInfo{Username: "alice"}
+	controller := authenticationv1.UserInfo{Username: "system:serviceaccount:default:operator"}
+
+	tests := []struct {
+		name    string
+		op      admissionv1.Operation
+		user    authenticationv1.UserInfo
+		object  map[string]string
+		old     map[string]string
+		labeled bool
+	}{
+		{name: "user create", op: admissionv1.Create, user: user, labeled: true},
+		{name: "user update", op: admissionv1.Update, user: user, object: map[string]string{tag.TraceyWebhookLabel: "old"}, old: map[string]string{tag.TraceyWebhookLabel: "old"}, labeled: true},
+		{name: "controller create", op: admissionv1.Create, user: controller},
+		{name: "user delete", op: admissionv1.Delete, user: user},
+		{name: "instrumented create", op: admissionv1.Create, user: user, object: map[string]string{tag.ChangeID: "c1"}},
+		{name: "instrumented update", op: admissionv1.Update, user: user, object: map[string]string{tag.ChangeID: "c2"}, old: map[string]string{tag.ChangeID: "c1"}},
+		{name: "user update of instrumented object", op: admissionv1.Update, user: user, object: map[string]string{tag.ChangeID: "c1"}, old: map[string]string{tag.ChangeID: "c1"}, labeled: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sink := client.NewMemorySink()
+			h := NewHandler(WithSink(sink))
+			req := admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+				Operation: tt.op,
+				UserInfo:  tt.user,
+				Kind:      metav1.GroupVersionKind{Version: "v1", Kind: "ConfigMap"},
+				Namespace: "default",
+				Object:    configMap(t, tt.object),
+			}}
+			if tt.op == admissionv1.Update {
+				req.OldObject = configMap(t, tt.old)
+			}
+
+			resp := h.Handle(context.Background(), req)
+			if !resp.Allowed {
+				t.Fatalf("expected request to be allowed: %v", resp.Result)
+			}
+			records := sink.Records()
+			if !tt.labeled {
+				if len(resp.Patches) != 0 || len(records) != 0 {
+					t.Errorf("expected no patches or records, got %v and %v", resp.Patches, records)
+				}
+				return
+			}
+
+			if len(records) != 1 || records[0].LogType != tag.RootEventKey {
+				t.Fatalf("expected a root event record, got %v", records)
+			}
+			var root event.RootEvent
+			if err := json.Unmarshal([]byte(records[0].Payload), &root); err != nil {
+				t.Fatalf("failed to decode root event: %v", err)
+			}
+			if root.User != "alice" || root.OpType != string(tt.op) || root.Kind != "ConfigMap" || root.Name != "cm" {
+				t.Errorf("unexpected root event: %+v", root)
+			}
+
+			var patched string
+			for _, p := range resp.Patches {
+				switch p.Path {
+				case "/metadata/labels":
+					patched = p.Value.(map[string]interface{})[tag.TraceyWebhookLabel].(string)
+				case "/metadata/labels/" + tag.TraceyWebhookLabel:
+					patched = p.Value.(string)
+				}
+			}
+			if patched == "" || patched != root.RootEventID {
+				t.Errorf("expected %s to be set to %q, got patches %v", tag.TraceyWebhookLabel, root.RootEventID, resp.Patches)
+			}
+		})
+	}
+}
+
+func TestUserUpdateOfControllerWrittenObject(t *testing.T) {
+	// the labels a traced controller leaves on an object it writes
+	written := map[string]string{
+		tag.TraceyWebhookLabel: "root-a",
+		tag.TraceyRootID:       "root-a",
+		tag.ChangeID:           "c1",
+	}
+	sink := client.NewMemorySink()
+	h := NewHandler(WithSink(sink))
+	req := admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+		Operation: admissionv1.Update,
+		UserInfo:  authenticationv1.UserInfo{Username: "alice"},
+		Kind:      metav1.GroupVersionKind{Version: "v1", Kind: "ConfigMap"},
+		Namespace: "default",
+		Object:    configMap(t, written),
+		OldObject: configMap(t, written),
+	}}
+	resp := h.Handle(context.Background(), req)
+	if !resp.Allowed {
+		t.Fatalf("expected request to be allowed: %v", resp.Result)
+	}
+	var root event.RootEvent
+	if err := json.Unmarshal([]byte(sink.Records()[0].Payload), &root); err != nil {
+		t.Fatalf("failed to decode root event: %v", err)
+	}
+
+	ops, err := json.Marshal(resp.Patches)
+	if err != nil {
+		t.Fatalf("failed to marshal patches: %v", err)
+	}
+	patch, err := jsonpatch.DecodePatch(ops)
+	if err != nil {
+		t.Fatalf("failed to decode patches: %v", err)
+	}
+	raw, err := patch.Apply(req.Object.Raw)
+	if err != nil {
+		t.Fatalf("failed to apply patches: %v", err)
+	}
+	admitted := &corev1.ConfigMap{}
+	if err := json.Unmarshal(raw, admitted); err != nil {
+		t.Fatalf("failed to decode admitted object: %v", err)
+	}
+
+	c := client.Wrap(fake.NewClientBuilder().WithObjects(admitted).Build()).
+		WithName("test-controller").
+		WithOptions(client.WithSink(client.NewMemorySink()))
+	ctx := client.WithReconcileID(context.Background(), "r1")
+	cm := &corev1.ConfigMap{}
+	if err := c.Get(ctx, types.NamespacedName{Namespace: "default", Name: "cm"}, cm); err != nil {
+		t.Fatalf("get failed: %v", err)
+	}
+	if got := cm.GetLabels()[tag.TraceyRootID]; got != root.RootEventID {
+		t.Errorf("expected the read to be rooted at %q, got %q", root.RootEventID, got)
+	}
+}
@@ -4,19 +4,52 @@ import (
 	"time"
 
 	"github.com/tgoodwin/sleeve/pkg/client"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	kclient "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 )
 
+// Deprecated: use VisibilityDelayFor.
 func VisibilityDelay(kind string, duration time.Duration) client.Option {
 	return client.VisibilityDelay(kind, duration)
 }
 
+func VisibilityDelayFor(gk schema.GroupKind, duration time.Duration) client.Option {
+	return client.VisibilityDelayFor(gk, duration)
+}
+
+func StaleReads(gk schema.GroupKind, policy client.StalePolicy) client.Option {
+	return client.StaleReads(gk, policy)
+}
+
+func InjectErrors(gk schema.GroupKind, policy client.ErrorPolicy) client.Option {
+	return client.InjectErrors(gk, policy)
+}
+
 func TrackSnapshots() client.Option {
 	return func(o *client.Config) {
 		o.LogObjectSnapshots = true
 	}
 }
 
+func RecordWriteDeltas() client.Option {
+	return client.RecordWriteDeltas()
+}
+
+func LimitPatchSize(maxBytes int) client.Option {
+	return client.LimitPatchSize(maxBytes)
+}
+
+func WithSink(sink client.TraceSink) client.Option {
+	return client.WithSink(sink)
+}
+
 func Wrap(wrapped kclient.Client) *client.Client {
 	return client.Wrap(wrapped)
 }
+
+// WrapReconciler records the beginning and end of every reconcile invocation.
+// The name should match the name given to the wrapped client.
+func WrapReconciler(name string, r reconcile.Reconciler, opts ...client.Option) *client.Reconciler {
+	return client.WrapReconciler(name, r, opts...)
+}